@@ -10,17 +10,100 @@ import (
 	"time"
 
 	httphandler "api-pubsub-logger/internal/http"
+	"api-pubsub-logger/internal/http/handlers"
 	"api-pubsub-logger/internal/pubsub"
+	"api-pubsub-logger/pkg/apilog"
+	"api-pubsub-logger/pkg/logger"
 
 	"github.com/kelseyhightower/envconfig"
 )
 
 type config struct {
-	Addr               string `envconfig:"ADDR" default:":8080"`
-	ServiceName        string `envconfig:"SERVICE_NAME" default:"api-pubsub-logger"`
-	Version            string `envconfig:"VERSION" default:"1.0.0"`
-	GoogleCloudProject string `envconfig:"GOOGLE_CLOUD_PROJECT" default:"demo-project"`
-	PubSubTopic        string `envconfig:"PUBSUB_TOPIC" default:"api-log-events"`
+	Addr               string        `envconfig:"ADDR" default:":8080"`
+	ServiceName        string        `envconfig:"SERVICE_NAME" default:"api-pubsub-logger"`
+	Version            string        `envconfig:"VERSION" default:"1.0.0"`
+	GoogleCloudProject string        `envconfig:"GOOGLE_CLOUD_PROJECT" default:"demo-project"`
+	PubSubTopic        string        `envconfig:"PUBSUB_TOPIC" default:"api-log-events"`
+	ShutdownTimeout    time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"30s"`
+	LogSink            []string      `envconfig:"LOG_SINK" default:"pubsub"`
+	LogSinkTimeout     time.Duration `envconfig:"LOG_SINK_TIMEOUT" default:"0s"`
+
+	StdoutFormat         string            `envconfig:"LOG_STDOUT_FORMAT" default:"json"`
+	FileDir              string            `envconfig:"LOG_FILE_DIR" default:"logs"`
+	FileMaxBytes         int64             `envconfig:"LOG_FILE_MAX_BYTES" default:"104857600"`
+	FileMaxFiles         int               `envconfig:"LOG_FILE_MAX_FILES" default:"10"`
+	WebhookURL           string            `envconfig:"LOG_WEBHOOK_URL"`
+	WebhookHeaders       map[string]string `envconfig:"LOG_WEBHOOK_HEADERS"`
+	WebhookBatchSize     int               `envconfig:"LOG_WEBHOOK_BATCH_SIZE" default:"100"`
+	WebhookFlushInterval time.Duration     `envconfig:"LOG_WEBHOOK_FLUSH_INTERVAL" default:"1s"`
+	WebhookTimeout       time.Duration     `envconfig:"LOG_WEBHOOK_TIMEOUT" default:"10s"`
+	WebhookMaxInFlight   int               `envconfig:"LOG_WEBHOOK_MAX_IN_FLIGHT" default:"4"`
+
+	PubSubCountThreshold         int               `envconfig:"PUBSUB_COUNT_THRESHOLD"`
+	PubSubByteThreshold          int               `envconfig:"PUBSUB_BYTE_THRESHOLD"`
+	PubSubDelayThreshold         time.Duration     `envconfig:"PUBSUB_DELAY_THRESHOLD"`
+	PubSubNumGoroutines          int               `envconfig:"PUBSUB_NUM_GOROUTINES"`
+	PubSubMaxOutstandingMessages int               `envconfig:"PUBSUB_MAX_OUTSTANDING_MESSAGES"`
+	PubSubMaxOutstandingBytes    int               `envconfig:"PUBSUB_MAX_OUTSTANDING_BYTES"`
+	PubSubFlowControl            string            `envconfig:"PUBSUB_FLOW_CONTROL"`
+	PubSubAsync                  bool              `envconfig:"PUBSUB_ASYNC" default:"false"`
+	PubSubAttributes             map[string]string `envconfig:"PUBSUB_ATTRIBUTES"`
+	PubSubOrderingKey            string            `envconfig:"PUBSUB_ORDERING_KEY"`
+	PubSubMaxBodyBytes           int               `envconfig:"PUBSUB_MAX_BODY_BYTES" default:"1048576"`
+	PubSubCompression            string            `envconfig:"PUBSUB_COMPRESSION"`
+	PubSubEncoding               string            `envconfig:"PUBSUB_ENCODING" default:"json"`
+	PubSubCloudEvents            string            `envconfig:"PUBSUB_CLOUDEVENTS"`
+	PubSubSigningKeys            map[string]string `envconfig:"PUBSUB_SIGNING_KEYS"`
+	PubSubSigningKeyID           string            `envconfig:"PUBSUB_SIGNING_KEY_ID"`
+
+	RetryMaxAttempts int           `envconfig:"PUBSUB_RETRY_MAX_ATTEMPTS" default:"5"`
+	RetryBaseDelay   time.Duration `envconfig:"PUBSUB_RETRY_BASE_DELAY" default:"100ms"`
+	RetryMaxDelay    time.Duration `envconfig:"PUBSUB_RETRY_MAX_DELAY" default:"5s"`
+	RetryJitter      float64       `envconfig:"PUBSUB_RETRY_JITTER" default:"0.2"`
+
+	BreakerEnabled          bool          `envconfig:"PUBSUB_BREAKER_ENABLED" default:"false"`
+	BreakerWindow           time.Duration `envconfig:"PUBSUB_BREAKER_WINDOW" default:"30s"`
+	BreakerMinRequests      int           `envconfig:"PUBSUB_BREAKER_MIN_REQUESTS" default:"10"`
+	BreakerFailureRate      float64       `envconfig:"PUBSUB_BREAKER_FAILURE_RATE" default:"0.5"`
+	BreakerCooldown         time.Duration `envconfig:"PUBSUB_BREAKER_COOLDOWN" default:"30s"`
+	BreakerHalfOpenRequests int           `envconfig:"PUBSUB_BREAKER_HALF_OPEN_REQUESTS" default:"1"`
+	BreakerFallback         string        `envconfig:"PUBSUB_BREAKER_FALLBACK"`
+
+	BodyLimit    int    `envconfig:"LOG_BODY_LIMIT" default:"1048576"`
+	SkipRules    string `envconfig:"LOG_SKIP_RULES"`
+	IncludeRules string `envconfig:"LOG_INCLUDE_RULES"`
+
+	SampleRate          float64            `envconfig:"LOG_SAMPLE_RATE" default:"1"`
+	SampleRouteRates    map[string]float64 `envconfig:"LOG_SAMPLE_ROUTE_RATES"`
+	SampleKeepErrors    bool               `envconfig:"LOG_SAMPLE_KEEP_ERRORS" default:"true"`
+	SampleSlowThreshold time.Duration      `envconfig:"LOG_SAMPLE_SLOW_THRESHOLD"`
+	SampleKeepUsers     []string           `envconfig:"LOG_SAMPLE_KEEP_USERS"`
+	SampleDeterministic bool               `envconfig:"LOG_SAMPLE_DETERMINISTIC" default:"false"`
+
+	CaptureHeaders bool     `envconfig:"LOG_CAPTURE_HEADERS" default:"false"`
+	HeadersAllow   []string `envconfig:"LOG_HEADERS_ALLOW"`
+	HeadersDeny    []string `envconfig:"LOG_HEADERS_DENY"`
+	HeadersRedact  []string `envconfig:"LOG_HEADERS_REDACT"`
+
+	RecoverPanics  bool `envconfig:"LOG_RECOVER_PANICS" default:"true"`
+	RepanicOnAbort bool `envconfig:"LOG_REPANIC_ON_ABORT" default:"true"`
+
+	TrustedProxies  []string `envconfig:"LOG_TRUSTED_PROXIES"`
+	ForwardedHeader string   `envconfig:"LOG_FORWARDED_HEADER" default:"X-Forwarded-For"`
+
+	EncryptionKeys  map[string]string `envconfig:"LOG_ENCRYPTION_KEYS"`
+	EncryptionKeyID string            `envconfig:"LOG_ENCRYPTION_KEY_ID"`
+
+	SpoolDir             string        `envconfig:"SPOOL_DIR"`
+	SpoolMaxSegmentBytes int64         `envconfig:"SPOOL_MAX_SEGMENT_BYTES" default:"10485760"`
+	SpoolMaxBytes        int64         `envconfig:"SPOOL_MAX_BYTES" default:"1073741824"`
+	SpoolReplayInterval  time.Duration `envconfig:"SPOOL_REPLAY_INTERVAL" default:"30s"`
+
+	DispatchQueueSize      int           `envconfig:"DISPATCH_QUEUE_SIZE" default:"1000"`
+	DispatchWorkers        int           `envconfig:"DISPATCH_WORKERS" default:"4"`
+	DispatchOverflow       string        `envconfig:"DISPATCH_OVERFLOW" default:"drop-newest"`
+	DispatchBlockTimeout   time.Duration `envconfig:"DISPATCH_BLOCK_TIMEOUT" default:"100ms"`
+	DispatchPublishTimeout time.Duration `envconfig:"DISPATCH_PUBLISH_TIMEOUT" default:"30s"`
 }
 
 func main() {
@@ -32,21 +115,105 @@ func main() {
 
 	log.Printf("Starting %s v%s on %s", cfg.ServiceName, cfg.Version, cfg.Addr)
 
-	// Initialize Pub/Sub client
+	// Events with the same ordering key are dispatched, retried and spooled one at a time
+	orderingKey, err := pubsub.OrderingKeyFromField(cfg.PubSubOrderingKey)
+	if err != nil {
+		log.Fatalf("Invalid ordering key: %v", err)
+	}
+	overflow, err := pubsub.ParseOverflowPolicy(cfg.DispatchOverflow)
+	if err != nil {
+		log.Fatalf("Invalid dispatch configuration: %v", err)
+	}
+	dispatchOptions := pubsub.DispatcherOptions{
+		QueueSize:      cfg.DispatchQueueSize,
+		Workers:        cfg.DispatchWorkers,
+		Overflow:       overflow,
+		BlockTimeout:   cfg.DispatchBlockTimeout,
+		PublishTimeout: cfg.DispatchPublishTimeout,
+		OrderingKey:    orderingKey,
+	}
+
+	// Initialize the log sinks, each spooling the events that still fail to disk
 	ctx := context.Background()
-	pubsubClient, err := pubsub.New(ctx, pubsub.Options{
-		ProjectID: cfg.GoogleCloudProject,
-		TopicName: cfg.PubSubTopic,
-	})
+	health := map[string]handlers.ComponentStatus{}
+	drains := map[string]func(context.Context) error{}
+	sinks, err := newSinks(ctx, cfg, dispatchOptions, health, drains)
 	if err != nil {
-		log.Fatalf("Failed to create Pub/Sub client: %v", err)
+		log.Fatalf("Failed to create log sinks: %v", err)
 	}
-	defer pubsubClient.Close()
+	publisher := sinks
 
-	log.Printf("Connected to Pub/Sub project: %s, topic: %s", cfg.GoogleCloudProject, cfg.PubSubTopic)
+	// Encrypt bodies before events reach the spool or any sink
+	if cfg.EncryptionKeyID != "" {
+		keys, err := logger.ParseKeyring(cfg.EncryptionKeys)
+		if err != nil {
+			log.Fatalf("Invalid encryption keys: %v", err)
+		}
+		encrypter, err := logger.NewBodyEncrypter(keys, cfg.EncryptionKeyID)
+		if err != nil {
+			log.Fatalf("Invalid encryption keys: %v", err)
+		}
+		publisher = pubsub.NewEncryptingPublisher(publisher, encrypter, cfg.PubSubMaxBodyBytes)
+	}
+	// Publish log events through a bounded queue so a slow sink cannot pile up goroutines
+	dispatcher := pubsub.NewDispatcher(publisher, dispatchOptions)
 
 	// Initialize HTTP handler
-	handler := httphandler.New(pubsubClient, cfg.ServiceName, cfg.Version)
+	handler := httphandler.New(dispatcher, cfg.ServiceName, cfg.Version)
+	handler.HealthComponents = health
+	handler.LoggingOptions = append(handler.LoggingOptions, apilog.WithBodyLimit(cfg.BodyLimit))
+	if cfg.SkipRules != "" {
+		rules, err := apilog.ParseRules(cfg.SkipRules)
+		if err != nil {
+			log.Fatalf("Invalid skip rules: %v", err)
+		}
+		handler.LoggingOptions = append(handler.LoggingOptions, apilog.WithSkipRules(rules...))
+	}
+	if cfg.IncludeRules != "" {
+		rules, err := apilog.ParseRules(cfg.IncludeRules)
+		if err != nil {
+			log.Fatalf("Invalid include rules: %v", err)
+		}
+		handler.LoggingOptions = append(handler.LoggingOptions, apilog.WithIncludeRules(rules...))
+	}
+	if cfg.SampleRate < 1 || len(cfg.SampleRouteRates) > 0 {
+		sampler, err := apilog.NewSampler(apilog.SamplingPolicy{
+			Rate:          cfg.SampleRate,
+			RouteRates:    cfg.SampleRouteRates,
+			KeepErrors:    cfg.SampleKeepErrors,
+			SlowThreshold: cfg.SampleSlowThreshold,
+			KeepUsers:     cfg.SampleKeepUsers,
+			Deterministic: cfg.SampleDeterministic,
+		})
+		if err != nil {
+			log.Fatalf("Invalid sampling configuration: %v", err)
+		}
+		handler.LoggingOptions = append(handler.LoggingOptions, apilog.WithSampler(sampler))
+	}
+	if cfg.CaptureHeaders {
+		handler.LoggingOptions = append(handler.LoggingOptions, apilog.WithHeaderCapture(apilog.HeaderPolicy{
+			Allow:  cfg.HeadersAllow,
+			Deny:   cfg.HeadersDeny,
+			Redact: cfg.HeadersRedact,
+		}))
+	}
+	if cfg.RecoverPanics {
+		handler.LoggingOptions = append(handler.LoggingOptions, apilog.WithRecovery(apilog.RecoveryOptions{
+			RepanicOnAbort: cfg.RepanicOnAbort,
+		}))
+	}
+	if len(cfg.TrustedProxies) > 0 {
+		proxies, err := apilog.ParseTrustedProxies(cfg.TrustedProxies)
+		if err != nil {
+			log.Fatalf("Invalid trusted proxies: %v", err)
+		}
+		header, err := apilog.ParseForwardedHeader(cfg.ForwardedHeader)
+		if err != nil {
+			log.Fatalf("Invalid forwarded header: %v", err)
+		}
+		handler.LoggingOptions = append(handler.LoggingOptions,
+			apilog.WithTrustedProxies(proxies...), apilog.WithForwardedHeader(header))
+	}
 
 	// Create HTTP server
 	srv := &http.Server{
@@ -73,11 +240,38 @@ func main() {
 	log.Println("Shutting down server...")
 
 	// Graceful shutdown with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		log.Printf("Server forced to shutdown: %v", err)
+	}
+
+	// Wait for pending API log events to be published within the same deadline
+	if err := dispatcher.Shutdown(ctx); err != nil {
+		log.Printf("Failed to drain API log events: %v", err)
+	}
+	stats := dispatcher.Stats()
+	log.Printf("API log events published: %d, failed: %d, dropped: %d", stats.Published, stats.Failed, stats.Dropped)
+	if fanout, ok := sinks.(*pubsub.FanoutPublisher); ok {
+		if err := fanout.Shutdown(ctx); err != nil {
+			log.Printf("Failed to drain API log events of the sinks: %v", err)
+		}
+		for name, sinkStats := range fanout.Stats() {
+			log.Printf("API log events for %s sink published: %d, failed: %d, dropped: %d", name, sinkStats.Published, sinkStats.Failed, sinkStats.Dropped)
+		}
+	}
+
+	// Post the pending webhook batches, the posts still in flight at the deadline are canceled and spooled
+	for name, drain := range drains {
+		if err := drain(ctx); err != nil {
+			log.Printf("Failed to drain API log events of the %s: %v", name, err)
+		}
+	}
+
+	// Close the publisher chain and the sinks once the workers have returned
+	if err := dispatcher.Close(); err != nil {
+		log.Printf("Failed to close log sinks: %v", err)
 	}
 
 	log.Println("Server stopped")
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"api-pubsub-logger/internal/http/handlers"
+	"api-pubsub-logger/internal/pubsub"
+	"api-pubsub-logger/pkg/logger"
+)
+
+// newSinks creates the publishers listed in LOG_SINK, fanning out to all of them when there are several
+// Each sink retries and spools its own failures so a blip on one sink does not resend to the others,
+// and has its own queue when fanning out so a slow sink does not hold back the others
+// Components worth reporting on the health endpoint are added to health, and components posting
+// in the background to drains, to be drained before the publisher chain is closed
+func newSinks(ctx context.Context, cfg config, dispatchOptions pubsub.DispatcherOptions, health map[string]handlers.ComponentStatus, drains map[string]func(context.Context) error) (pubsub.Publisher, error) {
+	if len(cfg.LogSink) == 0 {
+		return nil, errors.New("no log sink configured")
+	}
+
+	var sinks []pubsub.NamedPublisher
+	closeSinks := func() {
+		for _, created := range sinks {
+			created.Publisher.Close()
+		}
+	}
+	for _, name := range cfg.LogSink {
+		spool, err := openSpool(cfg, name)
+		if err != nil {
+			closeSinks()
+			return nil, fmt.Errorf("%s sink: %w", name, err)
+		}
+
+		failed := &failedEvents{}
+		publisher, err := newRetryingSink(ctx, cfg, name, failed.spool, health, drains)
+		if err != nil {
+			if spool != nil {
+				spool.Close()
+			}
+			closeSinks()
+			return nil, fmt.Errorf("%s sink: %w", name, err)
+		}
+
+		// Spool events that still fail to disk and replay them once the sink is reachable again
+		if spool != nil {
+			failed.publisher = pubsub.NewSpoolPublisher(publisher, spool, cfg.SpoolReplayInterval, dispatchOptions.OrderingKey)
+			publisher = failed.publisher
+		}
+
+		sinks = append(sinks, pubsub.NamedPublisher{Name: name, Publisher: publisher})
+	}
+
+	if len(sinks) == 1 {
+		return sinks[0].Publisher, nil
+	}
+	if cfg.LogSinkTimeout > 0 {
+		dispatchOptions.PublishTimeout = cfg.LogSinkTimeout
+	}
+	return pubsub.NewFanoutPublisher(dispatchOptions, sinks...), nil
+}
+
+// openSpool opens the spool of a sink in SPOOL_DIR, or in a subdirectory per sink when fanning out
+// It returns a nil spool when spooling is disabled
+func openSpool(cfg config, name string) (*pubsub.Spool, error) {
+	if cfg.SpoolDir == "" {
+		return nil, nil
+	}
+
+	dir := cfg.SpoolDir
+	if len(cfg.LogSink) > 1 {
+		dir = filepath.Join(cfg.SpoolDir, name)
+	}
+	spool, err := pubsub.OpenSpool(dir, cfg.SpoolMaxSegmentBytes, cfg.SpoolMaxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool: %w", err)
+	}
+	log.Printf("Spooling failed API log events for %s sink to %s", name, dir)
+	return spool, nil
+}
+
+// newRetryingSink creates a sink retrying its transient failures, behind a circuit breaker for Pub/Sub
+func newRetryingSink(ctx context.Context, cfg config, name string, onError func(logger.APILogEvent, error), health map[string]handlers.ComponentStatus, drains map[string]func(context.Context) error) (pubsub.Publisher, error) {
+	sink, err := newSink(ctx, cfg, name, onError)
+	if err != nil {
+		return nil, err
+	}
+	if webhook, ok := sink.(*pubsub.WebhookPublisher); ok {
+		drains[name+" sink"] = webhook.Shutdown
+	}
+
+	var publisher pubsub.Publisher = pubsub.NewRetryPublisher(sink, pubsub.RetryPolicy{
+		MaxAttempts: cfg.RetryMaxAttempts,
+		BaseDelay:   cfg.RetryBaseDelay,
+		MaxDelay:    cfg.RetryMaxDelay,
+		Jitter:      cfg.RetryJitter,
+	})
+
+	// Stop waiting on a degraded Pub/Sub and divert to the fallback sink instead
+	if name == "pubsub" && cfg.BreakerEnabled {
+		breaker, err := newBreaker(ctx, cfg, publisher, onError, drains)
+		if err != nil {
+			publisher.Close()
+			return nil, err
+		}
+		health["pubsub_breaker"] = breaker.Health
+		publisher = breaker
+	}
+	return publisher, nil
+}
+
+// newBreaker wraps the Pub/Sub publisher with a circuit breaker
+func newBreaker(ctx context.Context, cfg config, publisher pubsub.Publisher, onError func(logger.APILogEvent, error), drains map[string]func(context.Context) error) (*pubsub.BreakerPublisher, error) {
+	var fallback pubsub.Publisher
+	if cfg.BreakerFallback != "" {
+		var err error
+		fallback, err = newSink(ctx, cfg, cfg.BreakerFallback, onError)
+		if err != nil {
+			return nil, fmt.Errorf("%s fallback sink: %w", cfg.BreakerFallback, err)
+		}
+		if webhook, ok := fallback.(*pubsub.WebhookPublisher); ok {
+			drains[cfg.BreakerFallback+" fallback sink"] = webhook.Shutdown
+		}
+	}
+
+	return pubsub.NewBreakerPublisher(publisher, pubsub.BreakerOptions{
+		Window:           cfg.BreakerWindow,
+		MinRequests:      cfg.BreakerMinRequests,
+		FailureRate:      cfg.BreakerFailureRate,
+		Cooldown:         cfg.BreakerCooldown,
+		HalfOpenRequests: cfg.BreakerHalfOpenRequests,
+		Fallback:         fallback,
+	}), nil
+}
+
+// newSink creates a single publisher by name
+// onError receives the events that sinks publishing in the background failed to publish
+func newSink(ctx context.Context, cfg config, name string, onError func(logger.APILogEvent, error)) (pubsub.Publisher, error) {
+	switch name {
+	case "pubsub":
+		return newPubSubSink(ctx, cfg, onError)
+	case "stdout":
+		return pubsub.NewStdoutPublisher(os.Stdout, cfg.StdoutFormat)
+	case "file":
+		log.Printf("Writing API log events to %s", cfg.FileDir)
+		return pubsub.NewFilePublisher(cfg.FileDir, cfg.FileMaxBytes, cfg.FileMaxFiles)
+	case "webhook":
+		orderingKey, err := pubsub.OrderingKeyFromField(cfg.PubSubOrderingKey)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Posting API log events to %s", cfg.WebhookURL)
+		return pubsub.NewWebhookPublisher(pubsub.WebhookOptions{
+			URL:           cfg.WebhookURL,
+			Headers:       cfg.WebhookHeaders,
+			BatchSize:     cfg.WebhookBatchSize,
+			FlushInterval: cfg.WebhookFlushInterval,
+			Timeout:       cfg.WebhookTimeout,
+			MaxInFlight:   cfg.WebhookMaxInFlight,
+			OrderingKey:   orderingKey,
+			OnError:       onError,
+		})
+	default:
+		return nil, fmt.Errorf("unknown log sink %q", name)
+	}
+}
+
+// newPubSubSink creates the Pub/Sub client
+func newPubSubSink(ctx context.Context, cfg config, onError func(logger.APILogEvent, error)) (pubsub.Publisher, error) {
+	orderingKey, err := pubsub.OrderingKeyFromField(cfg.PubSubOrderingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	encoder, err := logger.NewEncoder(cfg.PubSubEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	var signer *logger.Signer
+	if cfg.PubSubSigningKeyID != "" {
+		keys, err := logger.ParseKeyring(cfg.PubSubSigningKeys)
+		if err != nil {
+			return nil, err
+		}
+		signer, err = logger.NewSigner(keys, cfg.PubSubSigningKeyID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	client, err := pubsub.New(ctx, pubsub.Options{
+		ProjectID:              cfg.GoogleCloudProject,
+		TopicName:              cfg.PubSubTopic,
+		CountThreshold:         cfg.PubSubCountThreshold,
+		ByteThreshold:          cfg.PubSubByteThreshold,
+		DelayThreshold:         cfg.PubSubDelayThreshold,
+		NumGoroutines:          cfg.PubSubNumGoroutines,
+		MaxOutstandingMessages: cfg.PubSubMaxOutstandingMessages,
+		MaxOutstandingBytes:    cfg.PubSubMaxOutstandingBytes,
+		FlowControl:            cfg.PubSubFlowControl,
+		Attributes:             cfg.PubSubAttributes,
+		OrderingKey:            orderingKey,
+		Encoder:                encoder,
+		CloudEvents:            cfg.PubSubCloudEvents,
+		Signer:                 signer,
+		MaxBodyBytes:           cfg.PubSubMaxBodyBytes,
+		Compression:            cfg.PubSubCompression,
+		Async:                  cfg.PubSubAsync,
+		OnAsyncError:           onError,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Connected to Pub/Sub project: %s, topic: %s", cfg.GoogleCloudProject, cfg.PubSubTopic)
+	return client, nil
+}
+
+// failedEvents passes the failures of sinks publishing in the background to the SpoolPublisher
+// wrapping them, which is created after the sink, so that it records their ordering keys
+// Their failures bypass the retry and spool decorators otherwise
+type failedEvents struct {
+	publisher *pubsub.SpoolPublisher
+}
+
+// spool spools a failed event, it is only logged by the sink when spooling is disabled
+func (f *failedEvents) spool(event logger.APILogEvent, err error) {
+	if f.publisher != nil {
+		f.publisher.SpoolFailed(event, err)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -9,6 +10,8 @@ import (
 	"strings"
 	"time"
 
+	"api-pubsub-logger/pkg/logger"
+
 	"cloud.google.com/go/pubsub"
 	"google.golang.org/api/iterator"
 )
@@ -20,6 +23,8 @@ var subcommands = []string{
 	"list-topics",
 	"subscribe-topic",
 	"delete-all-subscriptions",
+	"create-schema",
+	"bind-schema",
 }
 
 func main() {
@@ -33,10 +38,23 @@ func main() {
 	subscribeTopicCmd := flag.NewFlagSet("subscribe-topic", flag.ExitOnError)
 	subscribeTopicProjectID := subscribeTopicCmd.String("project-id", "", "Pub/Sub project ID")
 	subscribeTopicName := subscribeTopicCmd.String("topic", "", "Topic to be subscribed")
+	subscribeTopicVerify := subscribeTopicCmd.Bool("verify", false, "Verify message signatures and flag bad or missing ones")
+	subscribeTopicSigningKeys := subscribeTopicCmd.String("signing-keys", os.Getenv("PUBSUB_SIGNING_KEYS"), "Signing keys as comma-separated keyID:base64 pairs")
+	subscribeTopicDecryptionKeys := subscribeTopicCmd.String("decryption-keys", "", "Data keys decrypting request and response bodies as comma-separated keyID:base64 pairs")
 
 	deleteSubscriptionsCmd := flag.NewFlagSet("delete-all-subscriptions", flag.ExitOnError)
 	deleteSubscriptionsProjectID := deleteSubscriptionsCmd.String("project-id", "", "Pub/Sub project ID")
 
+	createSchemaCmd := flag.NewFlagSet("create-schema", flag.ExitOnError)
+	createSchemaProjectID := createSchemaCmd.String("project-id", "", "Pub/Sub project ID")
+	createSchemaName := createSchemaCmd.String("schema", "", "Schema to be created")
+	createSchemaType := createSchemaCmd.String("type", "protobuf", "Schema type: protobuf or avro")
+
+	bindSchemaCmd := flag.NewFlagSet("bind-schema", flag.ExitOnError)
+	bindSchemaProjectID := bindSchemaCmd.String("project-id", "", "Pub/Sub project ID")
+	bindSchemaTopic := bindSchemaCmd.String("topic", "", "Topic the schema is bound to")
+	bindSchemaName := bindSchemaCmd.String("schema", "", "Schema to be bound")
+
 	if len(os.Args) < 2 {
 		fmt.Println("Subcommand is required")
 		printHelp()
@@ -63,7 +81,29 @@ func main() {
 		if err := subscribeTopicCmd.Parse(os.Args[2:]); err != nil {
 			panic(err)
 		}
-		subscribeTopic(*subscribeTopicProjectID, *subscribeTopicName)
+		var signingKeys, decryptionKeys logger.Keyring
+		var err error
+		if *subscribeTopicVerify {
+			if signingKeys, err = parseKeys(*subscribeTopicSigningKeys); err != nil {
+				panic(err)
+			}
+		}
+		if *subscribeTopicDecryptionKeys != "" {
+			if decryptionKeys, err = parseKeys(*subscribeTopicDecryptionKeys); err != nil {
+				panic(err)
+			}
+		}
+		subscribeTopic(*subscribeTopicProjectID, *subscribeTopicName, signingKeys, decryptionKeys)
+	case "create-schema":
+		if err := createSchemaCmd.Parse(os.Args[2:]); err != nil {
+			panic(err)
+		}
+		createSchema(*createSchemaProjectID, *createSchemaName, *createSchemaType)
+	case "bind-schema":
+		if err := bindSchemaCmd.Parse(os.Args[2:]); err != nil {
+			panic(err)
+		}
+		bindSchema(*bindSchemaProjectID, *bindSchemaTopic, *bindSchemaName)
 	default:
 		fmt.Printf("Unknown subcommand %s\n", os.Args[1])
 		printHelp()
@@ -135,7 +175,9 @@ func listTopics(project string) {
 	}
 }
 
-func subscribeTopic(project, topic string) {
+// subscribeTopic prints the messages published to topic, verifying their signatures when signingKeys
+// is not nil and decrypting their bodies when decryptionKeys is not nil
+func subscribeTopic(project, topic string, signingKeys, decryptionKeys logger.Keyring) {
 	subscriptionName := fmt.Sprintf("%s_sub", topic)
 
 	client, err := newClient(project)
@@ -173,13 +215,63 @@ func subscribeTopic(project, topic string) {
 		msg.Ack()
 		fmt.Println("---")
 		fmt.Println("Received message:")
-		fmt.Println(string(msg.Data))
+		if signingKeys != nil {
+			if keyID, err := logger.VerifyMessage(msg.Data, msg.Attributes, signingKeys); err != nil {
+				fmt.Printf("WARNING: signature check failed: %v\n", err)
+			} else {
+				fmt.Printf("Signature verified with key '%s'\n", keyID)
+			}
+		}
+		fmt.Println(formatMessage(msg, decryptionKeys))
 		fmt.Println("---")
 	}); err != nil {
 		panic(err)
 	}
 }
 
+// parseKeys parses keyID:base64 pairs separated by commas
+func parseKeys(value string) (logger.Keyring, error) {
+	encoded := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		keyID, key, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid key %q, expected keyID:base64", pair)
+		}
+		encoded[keyID] = key
+	}
+	if len(encoded) == 0 {
+		return nil, errors.New("no keys given")
+	}
+	return logger.ParseKeyring(encoded)
+}
+
+// formatMessage decodes a message (whatever its compression and encoding) and returns it as JSON
+// Encrypted bodies are decrypted when decryptionKeys is not nil
+func formatMessage(msg *pubsub.Message, decryptionKeys logger.Keyring) string {
+	event, err := logger.DecodeMessage(msg.Data, msg.Attributes)
+	if err != nil {
+		return fmt.Sprintf("Failed to decode message (%v): %s", err, msg.Data)
+	}
+
+	if decryptionKeys != nil {
+		decrypted, err := logger.DecryptBodies(event, decryptionKeys)
+		if err != nil {
+			fmt.Printf("WARNING: failed to decrypt bodies: %v\n", err)
+		} else {
+			event = decrypted
+		}
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Sprintf("Failed to encode message: %v", err)
+	}
+	return string(data)
+}
+
 func deleteAllSubscriptions(project string) {
 	client, err := newClient(project)
 	if err != nil {
@@ -210,3 +302,74 @@ func deleteAllSubscriptions(project string) {
 		}
 	}
 }
+
+// createSchema creates a Pub/Sub schema from the APILogEvent definition embedded in the logger package
+func createSchema(project, schema, schemaType string) {
+	if project == "" {
+		panic("project is empty")
+	}
+	if schema == "" {
+		panic("schema is empty")
+	}
+
+	config := pubsub.SchemaConfig{}
+	switch schemaType {
+	case "protobuf":
+		config.Type = pubsub.SchemaProtocolBuffer
+		config.Definition = logger.ProtobufSchema
+	case "avro":
+		config.Type = pubsub.SchemaAvro
+		config.Definition = logger.AvroSchema
+	default:
+		panic(fmt.Sprintf("unknown schema type %q", schemaType))
+	}
+
+	client, err := pubsub.NewSchemaClient(context.Background(), project)
+	if err != nil {
+		panic(err)
+	}
+	defer client.Close()
+
+	fmt.Printf("Creating %s schema '%s' for project '%s'...\n", schemaType, schema, project)
+
+	s, err := client.CreateSchema(context.Background(), schema, config)
+	if err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			fmt.Printf("Schema '%s' already exists\n", schema)
+			return
+		}
+		panic(err)
+	}
+
+	fmt.Printf("Schema created: %s\n", s.Name)
+}
+
+// bindSchema binds a schema to a topic so published messages are validated against it
+// The API must publish with the matching PUBSUB_ENCODING (protobuf or avro) and no compression
+func bindSchema(project, topic, schema string) {
+	if topic == "" {
+		panic("topic is empty")
+	}
+	if schema == "" {
+		panic("schema is empty")
+	}
+
+	client, err := newClient(project)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Binding schema '%s' to topic '%s'...\n", schema, topic)
+
+	_, err = client.Topic(topic).Update(context.Background(), pubsub.TopicConfigToUpdate{
+		SchemaSettings: &pubsub.SchemaSettings{
+			Schema:   fmt.Sprintf("projects/%s/schemas/%s", project, schema),
+			Encoding: pubsub.EncodingBinary,
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println("Schema bound")
+}
@@ -3,7 +3,9 @@ package http
 import (
 	"net/http"
 
+	"api-pubsub-logger/internal/http/handlers"
 	"api-pubsub-logger/internal/pubsub"
+	"api-pubsub-logger/pkg/apilog"
 
 	"github.com/gorilla/mux"
 )
@@ -13,7 +15,11 @@ type Handler struct {
 	PubSubClient pubsub.Publisher
 	ServiceName  string
 	Version      string
-	router       *mux.Router
+	// HealthComponents are reported by the health endpoint (e.g. circuit breaker state)
+	HealthComponents map[string]handlers.ComponentStatus
+	// LoggingOptions configure the logging middleware (e.g. sampling)
+	LoggingOptions []apilog.Option
+	router         *mux.Router
 }
 
 // New creates a new HTTP handler with dependencies
@@ -5,13 +5,37 @@ import (
 	"net/http"
 )
 
+// ComponentStatus reports the state of a component and whether it is healthy
+type ComponentStatus func() (state string, healthy bool)
+
 type healthResponse struct {
-	Status string `json:"status"`
+	Status     string            `json:"status"`
+	Components map[string]string `json:"components,omitempty"`
 }
 
 // HealthCheck handles health check requests
 func HealthCheck(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(healthResponse{Status: "ok"})
+	NewHealthCheck(nil)(w, r)
+}
+
+// NewHealthCheck returns a health check handler that also reports the state of components
+// The service stays up when a component is unhealthy, so the status is "degraded" with a 200
+func NewHealthCheck(components map[string]ComponentStatus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := healthResponse{Status: "ok"}
+		if len(components) > 0 {
+			resp.Components = make(map[string]string, len(components))
+			for name, status := range components {
+				state, healthy := status()
+				resp.Components[name] = state
+				if !healthy {
+					resp.Status = "degraded"
+				}
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}
 }
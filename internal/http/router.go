@@ -4,7 +4,8 @@ import (
 	"net/http"
 
 	"api-pubsub-logger/internal/http/handlers"
-	"api-pubsub-logger/internal/http/middleware"
+	"api-pubsub-logger/pkg/apilog"
+	"api-pubsub-logger/pkg/apilog/apilogmux"
 
 	"github.com/gorilla/mux"
 )
@@ -14,12 +15,13 @@ func (h *Handler) Handler() http.Handler {
 	r := mux.NewRouter()
 
 	// Apply global middleware
-	r.Use(middleware.RequestIDMiddleware)
-	r.Use(middleware.UserIDMiddleware)
-	r.Use(middleware.LoggingMiddleware(h.PubSubClient, h.ServiceName))
+	r.Use(apilog.RequestIDMiddleware)
+	r.Use(apilog.UserIDMiddleware)
+	loggingOptions := append([]apilog.Option{apilog.WithServiceName(h.ServiceName)}, h.LoggingOptions...)
+	r.Use(apilogmux.LoggingMiddleware(h.PubSubClient, loggingOptions...))
 
 	// Health check endpoint (not logged due to skip in middleware)
-	r.Methods("GET").Path("/health").Name("health").HandlerFunc(handlers.HealthCheck)
+	r.Methods("GET").Path("/health").Name("health").HandlerFunc(handlers.NewHealthCheck(h.HealthComponents))
 
 	// Version 1 API routes
 	v1 := r.PathPrefix("/v1").Subrouter()
@@ -0,0 +1,62 @@
+package pubsub
+
+import (
+	"fmt"
+	"strconv"
+
+	"api-pubsub-logger/pkg/logger"
+)
+
+// attributeFields are the event fields that can be copied into message attributes
+var attributeFields = map[string]func(logger.APILogEvent) string{
+	"service":       func(e logger.APILogEvent) string { return e.Service },
+	"method":        func(e logger.APILogEvent) string { return e.Method },
+	"name":          func(e logger.APILogEvent) string { return e.Name },
+	"version":       func(e logger.APILogEvent) string { return e.Version },
+	"response_code": func(e logger.APILogEvent) string { return strconv.Itoa(e.ResponseCode) },
+	"response_code_class": func(e logger.APILogEvent) string {
+		if e.ResponseCode < 100 || e.ResponseCode > 599 {
+			return ""
+		}
+		return fmt.Sprintf("%dxx", e.ResponseCode/100)
+	},
+	"request_id":  func(e logger.APILogEvent) string { return e.RequestID.String },
+	"user_id":     func(e logger.APILogEvent) string { return e.UserID.String },
+	"has_user_id": func(e logger.APILogEvent) string { return strconv.FormatBool(e.UserID.Valid && e.UserID.String != "") },
+}
+
+// DefaultAttributes maps message attribute names to the event fields copied into them
+// when no mapping is configured
+var DefaultAttributes = map[string]string{
+	"service":             "service",
+	"method":              "method",
+	"route_name":          "name",
+	"version":             "version",
+	"response_code_class": "response_code_class",
+	"has_user_id":         "has_user_id",
+}
+
+// validateAttributes makes sure every attribute maps to a known event field
+func validateAttributes(mapping map[string]string) error {
+	for attr, field := range mapping {
+		if _, ok := attributeFields[field]; !ok {
+			return fmt.Errorf("attribute %q maps to unknown field %q", attr, field)
+		}
+	}
+	return nil
+}
+
+// buildAttributes returns the message attributes of an event, skipping empty values
+func buildAttributes(mapping map[string]string, event logger.APILogEvent) map[string]string {
+	if len(mapping) == 0 {
+		return nil
+	}
+
+	attrs := make(map[string]string, len(mapping))
+	for attr, field := range mapping {
+		if value := attributeFields[field](event); value != "" {
+			attrs[attr] = value
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,102 @@
+package pubsub
+
+import (
+	"reflect"
+	"testing"
+
+	"api-pubsub-logger/pkg/logger"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestBuildAttributes(t *testing.T) {
+	event := logger.APILogEvent{
+		Service:      "test-service",
+		Method:       "POST",
+		Name:         "create_item",
+		Version:      "v1",
+		ResponseCode: 503,
+		UserID:       null.StringFrom("user-456"),
+	}
+
+	tests := []struct {
+		name     string
+		mapping  map[string]string
+		event    logger.APILogEvent
+		expected map[string]string
+	}{
+		{
+			name:    "default mapping",
+			mapping: DefaultAttributes,
+			event:   event,
+			expected: map[string]string{
+				"service":             "test-service",
+				"method":              "POST",
+				"route_name":          "create_item",
+				"version":             "v1",
+				"response_code_class": "5xx",
+				"has_user_id":         "true",
+			},
+		},
+		{
+			name:    "custom mapping",
+			mapping: map[string]string{"status": "response_code", "user": "user_id"},
+			event:   event,
+			expected: map[string]string{
+				"status": "503",
+				"user":   "user-456",
+			},
+		},
+		{
+			name:    "skips empty values",
+			mapping: map[string]string{"route_name": "name", "version": "version", "has_user_id": "has_user_id"},
+			event:   logger.APILogEvent{ResponseCode: 200},
+			expected: map[string]string{
+				"has_user_id": "false",
+			},
+		},
+		{
+			name:     "empty mapping disables attributes",
+			mapping:  map[string]string{},
+			event:    event,
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildAttributes(tt.mapping, tt.event)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("buildAttributes() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResponseCodeClass(t *testing.T) {
+	classOf := attributeFields["response_code_class"]
+
+	tests := map[int]string{
+		200: "2xx",
+		201: "2xx",
+		404: "4xx",
+		500: "5xx",
+		0:   "",
+		999: "",
+	}
+	for code, expected := range tests {
+		if got := classOf(logger.APILogEvent{ResponseCode: code}); got != expected {
+			t.Errorf("response_code_class(%d) = %v, want %v", code, got, expected)
+		}
+	}
+}
+
+func TestValidateAttributes(t *testing.T) {
+	if err := validateAttributes(DefaultAttributes); err != nil {
+		t.Errorf("Expected default attributes to be valid, got %v", err)
+	}
+
+	if err := validateAttributes(map[string]string{"foo": "unknown_field"}); err == nil {
+		t.Error("Expected error for unknown field")
+	}
+}
@@ -0,0 +1,218 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"api-pubsub-logger/pkg/logger"
+)
+
+// ErrBreakerOpen is returned when an event is rejected because the circuit breaker is open
+var ErrBreakerOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a circuit breaker
+type BreakerState int
+
+const (
+	// BreakerClosed lets every event through
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects (or diverts) every event until the cooldown elapses
+	BreakerOpen
+	// BreakerHalfOpen lets a few trial events through to probe for recovery
+	BreakerHalfOpen
+)
+
+// String returns the name of the state
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerOptions contains configuration options for the BreakerPublisher
+type BreakerOptions struct {
+	// Window is the period over which the failure rate is computed
+	Window time.Duration
+	// MinRequests is the number of requests in a window before the breaker can trip
+	MinRequests int
+	// FailureRate (0 to 1) trips the breaker once reached within a window
+	FailureRate float64
+	// Cooldown is how long the breaker stays open before probing again
+	Cooldown time.Duration
+	// HalfOpenRequests is the number of successful trial requests needed to close the breaker
+	HalfOpenRequests int
+	// Fallback receives the events rejected while the breaker is open, they fail fast when nil
+	Fallback Publisher
+}
+
+// BreakerPublisher is a Publisher decorator that stops calling a failing publisher
+// for a cooldown period instead of waiting on it for every event
+type BreakerPublisher struct {
+	next Publisher
+	opts BreakerOptions
+	now  func() time.Time
+
+	mu          sync.Mutex
+	state       BreakerState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	trials      int
+	successes   int
+}
+
+// NewBreakerPublisher wraps next with a circuit breaker
+func NewBreakerPublisher(next Publisher, opts BreakerOptions) *BreakerPublisher {
+	if opts.Window <= 0 {
+		opts.Window = 30 * time.Second
+	}
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = 10
+	}
+	if opts.FailureRate <= 0 {
+		opts.FailureRate = 0.5
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = 30 * time.Second
+	}
+	if opts.HalfOpenRequests <= 0 {
+		opts.HalfOpenRequests = 1
+	}
+
+	return &BreakerPublisher{
+		next: next,
+		opts: opts,
+		now:  time.Now,
+	}
+}
+
+// PublishAPILogEvent publishes an API log event unless the breaker is open
+func (b *BreakerPublisher) PublishAPILogEvent(ctx context.Context, event logger.APILogEvent) error {
+	if !b.allow() {
+		if b.opts.Fallback != nil {
+			return b.opts.Fallback.PublishAPILogEvent(ctx, event)
+		}
+		return ErrBreakerOpen
+	}
+
+	err := b.next.PublishAPILogEvent(ctx, event)
+	b.record(err)
+	return err
+}
+
+// State returns the current state of the breaker
+func (b *BreakerPublisher) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && !b.now().Before(b.openedAt.Add(b.opts.Cooldown)) {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// Health reports the breaker state for the health endpoint, it is unhealthy while open
+func (b *BreakerPublisher) Health() (string, bool) {
+	state := b.State()
+	return state.String(), state != BreakerOpen
+}
+
+// Close closes the underlying publisher and the fallback
+func (b *BreakerPublisher) Close() error {
+	err := b.next.Close()
+	if b.opts.Fallback != nil {
+		err = errors.Join(err, b.opts.Fallback.Close())
+	}
+	return err
+}
+
+// allow reports whether a request may go through to the underlying publisher
+func (b *BreakerPublisher) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Before(b.openedAt.Add(b.opts.Cooldown)) {
+			return false
+		}
+		b.setState(BreakerHalfOpen)
+		b.trials = 0
+		b.successes = 0
+		fallthrough
+	case BreakerHalfOpen:
+		if b.trials >= b.opts.HalfOpenRequests {
+			return false
+		}
+		b.trials++
+		return true
+	default:
+		return true
+	}
+}
+
+// record updates the breaker with the outcome of a request
+func (b *BreakerPublisher) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+
+	switch b.state {
+	case BreakerOpen:
+		// A request that started before the breaker opened
+		return
+	case BreakerHalfOpen:
+		if err != nil {
+			b.open(now)
+			return
+		}
+		b.successes++
+		if b.successes >= b.opts.HalfOpenRequests {
+			b.setState(BreakerClosed)
+			b.resetWindow(now)
+		}
+		return
+	}
+
+	if now.Sub(b.windowStart) >= b.opts.Window {
+		b.resetWindow(now)
+	}
+	b.requests++
+	if err != nil {
+		b.failures++
+	}
+
+	if b.requests >= b.opts.MinRequests && float64(b.failures)/float64(b.requests) >= b.opts.FailureRate {
+		b.open(now)
+	}
+}
+
+func (b *BreakerPublisher) open(now time.Time) {
+	b.setState(BreakerOpen)
+	b.openedAt = now
+}
+
+func (b *BreakerPublisher) resetWindow(now time.Time) {
+	b.windowStart = now
+	b.requests = 0
+	b.failures = 0
+}
+
+func (b *BreakerPublisher) setState(state BreakerState) {
+	if b.state != state {
+		log.Printf("Circuit breaker state changed from %s to %s", b.state, state)
+		b.state = state
+	}
+}
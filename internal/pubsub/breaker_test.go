@@ -0,0 +1,194 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock for breaker tests
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestBreaker(next Publisher, opts BreakerOptions) (*BreakerPublisher, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	b := NewBreakerPublisher(next, opts)
+	b.now = clock.Now
+	return b, clock
+}
+
+func setPublishError(m *mockPublisher, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.publishError = err
+}
+
+func TestBreakerPublisher_OpensOnFailureRate(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		successes int
+		wantState BreakerState
+	}{
+		{name: "below min requests", failures: 3, wantState: BreakerClosed},
+		{name: "below failure rate", failures: 1, successes: 3, wantState: BreakerClosed},
+		{name: "at failure rate", failures: 2, successes: 2, wantState: BreakerOpen},
+		{name: "all failures", failures: 4, wantState: BreakerOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &mockPublisher{}
+			b, _ := newTestBreaker(next, BreakerOptions{MinRequests: 4, FailureRate: 0.5})
+
+			for i := 0; i < tt.successes; i++ {
+				b.PublishAPILogEvent(context.Background(), newTestEvent("ok"))
+			}
+			setPublishError(next, errors.New("unavailable"))
+			for i := 0; i < tt.failures; i++ {
+				b.PublishAPILogEvent(context.Background(), newTestEvent("fail"))
+			}
+
+			if b.State() != tt.wantState {
+				t.Errorf("Expected state = %s, got %s", tt.wantState, b.State())
+			}
+		})
+	}
+}
+
+func TestBreakerPublisher_WindowResets(t *testing.T) {
+	next := &mockPublisher{publishError: errors.New("unavailable")}
+	b, clock := newTestBreaker(next, BreakerOptions{Window: time.Minute, MinRequests: 2})
+
+	b.PublishAPILogEvent(context.Background(), newTestEvent("req-1"))
+	clock.Advance(2 * time.Minute)
+	b.PublishAPILogEvent(context.Background(), newTestEvent("req-2"))
+
+	if b.State() != BreakerClosed {
+		t.Errorf("Expected failures in different windows not to open the breaker, got %s", b.State())
+	}
+}
+
+func TestBreakerPublisher_FailsFastWhenOpen(t *testing.T) {
+	next := &mockPublisher{publishError: errors.New("unavailable")}
+	b, _ := newTestBreaker(next, BreakerOptions{MinRequests: 1})
+
+	b.PublishAPILogEvent(context.Background(), newTestEvent("req-1"))
+
+	err := b.PublishAPILogEvent(context.Background(), newTestEvent("req-2"))
+	if !errors.Is(err, ErrBreakerOpen) {
+		t.Errorf("Expected ErrBreakerOpen, got %v", err)
+	}
+	if len(next.getEvents()) != 1 {
+		t.Errorf("Expected open breaker not to call the publisher, got %d calls", len(next.getEvents()))
+	}
+
+	if state, healthy := b.Health(); healthy || state != "open" {
+		t.Errorf("Expected health = open/unhealthy, got %s/%v", state, healthy)
+	}
+}
+
+func TestBreakerPublisher_DivertsToFallback(t *testing.T) {
+	next := &mockPublisher{publishError: errors.New("unavailable")}
+	fallback := &mockPublisher{}
+	b, _ := newTestBreaker(next, BreakerOptions{MinRequests: 1, Fallback: fallback})
+
+	b.PublishAPILogEvent(context.Background(), newTestEvent("req-1"))
+
+	if err := b.PublishAPILogEvent(context.Background(), newTestEvent("req-2")); err != nil {
+		t.Errorf("Expected fallback to accept the event, got %v", err)
+	}
+	events := fallback.getEvents()
+	if len(events) != 1 || events[0].RequestID.String != "req-2" {
+		t.Errorf("Expected fallback to receive req-2, got %+v", events)
+	}
+
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !next.closed || !fallback.closed {
+		t.Error("Expected publisher and fallback to be closed")
+	}
+}
+
+func TestBreakerPublisher_HalfOpen(t *testing.T) {
+	tests := []struct {
+		name       string
+		trialError error
+		wantState  BreakerState
+	}{
+		{name: "trial success closes", wantState: BreakerClosed},
+		{name: "trial failure reopens", trialError: errors.New("still unavailable"), wantState: BreakerOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &mockPublisher{publishError: errors.New("unavailable")}
+			b, clock := newTestBreaker(next, BreakerOptions{MinRequests: 1, Cooldown: time.Minute})
+
+			b.PublishAPILogEvent(context.Background(), newTestEvent("req-1"))
+			clock.Advance(time.Minute)
+
+			if b.State() != BreakerHalfOpen {
+				t.Fatalf("Expected state = half-open after cooldown, got %s", b.State())
+			}
+
+			setPublishError(next, tt.trialError)
+			err := b.PublishAPILogEvent(context.Background(), newTestEvent("trial"))
+			if err != tt.trialError {
+				t.Errorf("Expected trial error = %v, got %v", tt.trialError, err)
+			}
+
+			if b.State() != tt.wantState {
+				t.Errorf("Expected state = %s, got %s", tt.wantState, b.State())
+			}
+		})
+	}
+}
+
+func TestBreakerPublisher_LimitsTrialRequests(t *testing.T) {
+	next := &mockPublisher{publishError: errors.New("unavailable")}
+	b, clock := newTestBreaker(next, BreakerOptions{MinRequests: 1, Cooldown: time.Minute})
+
+	b.PublishAPILogEvent(context.Background(), newTestEvent("req-1"))
+	clock.Advance(time.Minute)
+
+	// Hold the trial request in flight so the next one is rejected
+	gate := make(chan struct{})
+	next.mu.Lock()
+	next.gate = gate
+	next.publishError = nil
+	next.mu.Unlock()
+
+	done := make(chan error)
+	go func() {
+		done <- b.PublishAPILogEvent(context.Background(), newTestEvent("trial"))
+	}()
+	waitFor(t, func() bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.trials == 1
+	})
+
+	if err := b.PublishAPILogEvent(context.Background(), newTestEvent("req-2")); !errors.Is(err, ErrBreakerOpen) {
+		t.Errorf("Expected ErrBreakerOpen while the trial is in flight, got %v", err)
+	}
+
+	close(gate)
+	if err := <-done; err != nil {
+		t.Errorf("Expected trial to succeed, got %v", err)
+	}
+	if b.State() != BreakerClosed {
+		t.Errorf("Expected state = closed, got %s", b.State())
+	}
+}
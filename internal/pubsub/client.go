@@ -3,64 +3,346 @@ package pubsub
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
+	"sync"
+	"time"
 
 	"api-pubsub-logger/pkg/logger"
 
 	"cloud.google.com/go/pubsub"
+	"google.golang.org/api/option"
+)
+
+var (
+	// ErrClientClosed is returned when publishing asynchronously after the client was closed
+	ErrClientClosed = errors.New("pubsub client is closed")
+	// ErrMessageTooLarge is returned when an encoded event exceeds the Pub/Sub message size limit
+	ErrMessageTooLarge = errors.New("message exceeds the Pub/Sub size limit")
+)
+
+const (
+	// asyncResultBuffer is the number of unresolved publish results buffered in async mode
+	asyncResultBuffer = 1000
+	// maxMessageBytes is the Pub/Sub limit on the size of a message's data
+	maxMessageBytes = 10_000_000
 )
 
 // Client is the Pub/Sub client wrapper
 type Client struct {
 	client *pubsub.Client
 	topic  *pubsub.Topic
+
+	attributes   map[string]string
+	orderingKey  func(logger.APILogEvent) string
+	encoder      logger.Encoder
+	cloudEvents  string
+	maxBodyBytes int
+	compression  string
+	signer       *logger.Signer
+	async        bool
+	onAsyncError func(logger.APILogEvent, error)
+	results      chan pendingResult
+	resolverDone chan struct{}
+
+	// mu guards closed and the results channel against sends after close
+	mu     sync.RWMutex
+	closed bool
+}
+
+// pendingResult is a publish result waiting to be resolved in async mode
+type pendingResult struct {
+	event       logger.APILogEvent
+	orderingKey string
+	result      *pubsub.PublishResult
 }
 
 // Options contains configuration options for the Pub/Sub client
 type Options struct {
 	ProjectID string
 	TopicName string
+	// ClientOptions are passed to the underlying client (e.g. to point it at a pstest server)
+	ClientOptions []option.ClientOption
+
+	// Batching and flow control settings of the topic, zero values keep the client defaults
+	CountThreshold         int
+	ByteThreshold          int
+	DelayThreshold         time.Duration
+	NumGoroutines          int
+	MaxOutstandingMessages int
+	MaxOutstandingBytes    int
+	// FlowControl is the behavior when outstanding limits are exceeded: block, ignore or signal-error
+	FlowControl string
+
+	// Attributes maps message attribute names to event fields (see DefaultAttributes, used when nil)
+	// An empty non-nil map disables attributes
+	Attributes map[string]string
+
+	// OrderingKey derives the ordering key of each message and enables ordered delivery when set
+	// (see OrderingKeyFromField), events with an empty key are published unordered
+	OrderingKey func(logger.APILogEvent) string
+
+	// Encoder encodes message data, JSON is used when nil
+	// Non-JSON encodings set the content-type attribute
+	Encoder logger.Encoder
+	// CloudEvents wraps events in CloudEvents 1.0, in structured or binary mode (disabled when empty)
+	CloudEvents string
+
+	// MaxBodyBytes truncates request and response bodies to this size before publishing, zero disables it
+	MaxBodyBytes int
+	// Compression compresses message data with gzip or zstd and sets the content-encoding attribute
+	Compression string
+
+	// Signer signs the final message data, the signature and key ID are set as attributes
+	Signer *logger.Signer
+
+	// Async makes PublishAPILogEvent return as soon as the message is handed to the batcher
+	// Results are resolved in the background and failures are passed to OnAsyncError
+	Async        bool
+	OnAsyncError func(logger.APILogEvent, error)
 }
 
 // New creates a new Pub/Sub client
 func New(ctx context.Context, opts Options) (*Client, error) {
-	client, err := pubsub.NewClient(ctx, opts.ProjectID)
+	settings, err := opts.publishSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := opts.Attributes
+	if attributes == nil {
+		attributes = DefaultAttributes
+	}
+	if err := validateAttributes(attributes); err != nil {
+		return nil, err
+	}
+
+	if err := logger.ValidateEncoding(opts.Compression); err != nil {
+		return nil, err
+	}
+
+	if err := logger.ValidateCloudEventsMode(opts.CloudEvents); err != nil {
+		return nil, err
+	}
+
+	encoder := opts.Encoder
+	if encoder == nil {
+		encoder = logger.JSONEncoder{}
+	}
+
+	client, err := pubsub.NewClient(ctx, opts.ProjectID, opts.ClientOptions...)
 	if err != nil {
 		return nil, err
 	}
 
 	topic := client.Topic(opts.TopicName)
+	topic.PublishSettings = settings
+	topic.EnableMessageOrdering = opts.OrderingKey != nil
+
+	c := &Client{
+		client:       client,
+		topic:        topic,
+		attributes:   attributes,
+		orderingKey:  opts.OrderingKey,
+		encoder:      encoder,
+		cloudEvents:  opts.CloudEvents,
+		maxBodyBytes: opts.MaxBodyBytes,
+		compression:  opts.Compression,
+		signer:       opts.Signer,
+		async:        opts.Async,
+		onAsyncError: opts.OnAsyncError,
+	}
 
-	return &Client{
-		client: client,
-		topic:  topic,
-	}, nil
+	if c.async {
+		c.results = make(chan pendingResult, asyncResultBuffer)
+		c.resolverDone = make(chan struct{})
+		go c.resolveResults()
+	}
+
+	return c, nil
+}
+
+// publishSettings applies the batching and flow control options on top of the client defaults
+func (opts Options) publishSettings() (pubsub.PublishSettings, error) {
+	settings := pubsub.DefaultPublishSettings
+
+	if opts.CountThreshold > 0 {
+		settings.CountThreshold = opts.CountThreshold
+	}
+	if opts.ByteThreshold > 0 {
+		settings.ByteThreshold = opts.ByteThreshold
+	}
+	if opts.DelayThreshold > 0 {
+		settings.DelayThreshold = opts.DelayThreshold
+	}
+	if opts.NumGoroutines > 0 {
+		settings.NumGoroutines = opts.NumGoroutines
+	}
+	if opts.MaxOutstandingMessages > 0 {
+		settings.FlowControlSettings.MaxOutstandingMessages = opts.MaxOutstandingMessages
+	}
+	if opts.MaxOutstandingBytes > 0 {
+		settings.FlowControlSettings.MaxOutstandingBytes = opts.MaxOutstandingBytes
+	}
+
+	switch strings.ToLower(opts.FlowControl) {
+	case "":
+	case "block":
+		settings.FlowControlSettings.LimitExceededBehavior = pubsub.FlowControlBlock
+	case "ignore":
+		settings.FlowControlSettings.LimitExceededBehavior = pubsub.FlowControlIgnore
+	case "signal-error":
+		settings.FlowControlSettings.LimitExceededBehavior = pubsub.FlowControlSignalError
+	default:
+		return settings, fmt.Errorf("unknown flow control behavior %q", opts.FlowControl)
+	}
+
+	return settings, nil
 }
 
 // PublishAPILogEvent publishes an API log event to Pub/Sub
 func (c *Client) PublishAPILogEvent(ctx context.Context, event logger.APILogEvent) error {
-	data, err := json.Marshal(event)
+	msg, err := c.newMessage(event)
 	if err != nil {
-		log.Printf("Error marshaling API log event: %v", err)
+		log.Printf("Error encoding API log event: %v", err)
 		return err
 	}
 
-	result := c.topic.Publish(ctx, &pubsub.Message{
-		Data: data,
-	})
+	if c.async {
+		return c.publishAsync(ctx, event, msg)
+	}
+
+	result := c.topic.Publish(ctx, msg)
 
 	// Get the server-generated message ID
 	_, err = result.Get(ctx)
 	if err != nil {
 		log.Printf("Error publishing API log event: %v", err)
+		c.resumePublish(msg.OrderingKey)
 		return err
 	}
 
 	return nil
 }
 
+// newMessage encodes an API log event into a Pub/Sub message
+func (c *Client) newMessage(event logger.APILogEvent) (*pubsub.Message, error) {
+	event = event.TruncateBodies(c.maxBodyBytes)
+
+	data, err := c.encoder.Encode(event)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := c.encoder.ContentType()
+	if c.cloudEvents == logger.CloudEventsStructured {
+		data, err = json.Marshal(logger.NewCloudEvent(event, data, contentType))
+		if err != nil {
+			return nil, err
+		}
+		contentType = logger.ContentTypeCloudEvents
+	}
+
+	data, err = logger.Compress(data, c.compression)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxMessageBytes {
+		return nil, fmt.Errorf("%w: %d bytes", ErrMessageTooLarge, len(data))
+	}
+
+	msg := &pubsub.Message{
+		Data:       data,
+		Attributes: buildAttributes(c.attributes, event),
+	}
+	if c.cloudEvents == logger.CloudEventsBinary {
+		for name, value := range logger.CloudEventAttributes(event) {
+			msg.Attributes = setAttribute(msg.Attributes, name, value)
+		}
+	}
+	// Binary mode CloudEvents always carry the data content type
+	if contentType != logger.ContentTypeJSON || c.cloudEvents == logger.CloudEventsBinary {
+		msg.Attributes = setAttribute(msg.Attributes, logger.ContentTypeAttribute, contentType)
+	}
+	if c.compression != "" {
+		msg.Attributes = setAttribute(msg.Attributes, logger.ContentEncodingAttribute, c.compression)
+	}
+	// Signed last, so that the signature covers every other attribute
+	if c.signer != nil {
+		for name, value := range c.signer.SignatureAttributes(data, msg.Attributes) {
+			msg.Attributes = setAttribute(msg.Attributes, name, value)
+		}
+	}
+	if c.orderingKey != nil {
+		msg.OrderingKey = c.orderingKey(event)
+	}
+	return msg, nil
+}
+
+// setAttribute sets a message attribute, allocating the attributes if needed
+func setAttribute(attrs map[string]string, name, value string) map[string]string {
+	if attrs == nil {
+		attrs = make(map[string]string, 1)
+	}
+	attrs[name] = value
+	return attrs
+}
+
+// resumePublish resumes publishing for an ordering key paused by a failed publish
+// Messages for the key published after the failure fail with ErrPublishingPaused,
+// which IsRetryable treats as transient so they are retried once the key is resumed
+func (c *Client) resumePublish(orderingKey string) {
+	if orderingKey != "" {
+		c.topic.ResumePublish(orderingKey)
+	}
+}
+
+// publishAsync hands the message to the batcher and queues its result for the resolver
+func (c *Client) publishAsync(ctx context.Context, event logger.APILogEvent, msg *pubsub.Message) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return ErrClientClosed
+	}
+
+	result := c.topic.Publish(ctx, msg)
+	c.results <- pendingResult{event: event, orderingKey: msg.OrderingKey, result: result}
+	return nil
+}
+
+// resolveResults waits for async publish results and reports failures
+func (c *Client) resolveResults() {
+	defer close(c.resolverDone)
+	for pending := range c.results {
+		if _, err := pending.result.Get(context.Background()); err != nil {
+			log.Printf("Error publishing API log event: %v", err)
+			// Report the failure while the key is still paused, so it can be spooled before
+			// a later event with the same key is published
+			if c.onAsyncError != nil {
+				c.onAsyncError(pending.event, err)
+			}
+			c.resumePublish(pending.orderingKey)
+		}
+	}
+}
+
 // Close closes the Pub/Sub client
 func (c *Client) Close() error {
+	// Stop flushes outstanding messages, so every async result resolves before the resolver exits
 	c.topic.Stop()
+
+	if c.async {
+		c.mu.Lock()
+		if !c.closed {
+			c.closed = true
+			close(c.results)
+		}
+		c.mu.Unlock()
+		<-c.resolverDone
+	}
+
 	return c.client.Close()
 }
@@ -0,0 +1,345 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"api-pubsub-logger/pkg/logger"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"gopkg.in/guregu/null.v3"
+)
+
+const (
+	testProjectID = "test-project"
+	testTopicName = "api-log-events"
+)
+
+// newTestClient starts a pstest fake server with the test topic and returns a Client connected to it
+func newTestClient(t *testing.T, opts Options) (*Client, *pstest.Server) {
+	t.Helper()
+
+	srv := pstest.NewServer()
+	t.Cleanup(func() { srv.Close() })
+
+	opts.ProjectID = testProjectID
+	opts.TopicName = testTopicName
+	opts.ClientOptions = []option.ClientOption{
+		option.WithEndpoint(srv.Addr),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	}
+
+	ctx := context.Background()
+	admin, err := pubsub.NewClient(ctx, testProjectID, opts.ClientOptions...)
+	if err != nil {
+		t.Fatalf("Failed to create admin client: %v", err)
+	}
+	defer admin.Close()
+	if _, err := admin.CreateTopic(ctx, testTopicName); err != nil {
+		t.Fatalf("Failed to create topic: %v", err)
+	}
+
+	client, err := New(ctx, opts)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return client, srv
+}
+
+func TestClient_PublishAPILogEvent(t *testing.T) {
+	client, srv := newTestClient(t, Options{})
+
+	event := newTestEvent("req-123")
+	if err := client.PublishAPILogEvent(context.Background(), event); err != nil {
+		t.Fatalf("PublishAPILogEvent() error = %v", err)
+	}
+
+	messages := srv.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(messages))
+	}
+
+	var published logger.APILogEvent
+	if err := json.Unmarshal(messages[0].Data, &published); err != nil {
+		t.Fatalf("Failed to decode message: %v", err)
+	}
+
+	if published.RequestID.String != "req-123" {
+		t.Errorf("Expected request ID = req-123, got %v", published.RequestID)
+	}
+}
+
+func TestOptions_PublishSettings(t *testing.T) {
+	opts := Options{
+		CountThreshold:         10,
+		ByteThreshold:          2048,
+		DelayThreshold:         50 * time.Millisecond,
+		NumGoroutines:          3,
+		MaxOutstandingMessages: 500,
+		MaxOutstandingBytes:    1 << 20,
+		FlowControl:            "block",
+	}
+
+	settings, err := opts.publishSettings()
+	if err != nil {
+		t.Fatalf("publishSettings() error = %v", err)
+	}
+
+	if settings.CountThreshold != 10 || settings.ByteThreshold != 2048 || settings.DelayThreshold != 50*time.Millisecond {
+		t.Errorf("Unexpected batching settings: %+v", settings)
+	}
+	if settings.NumGoroutines != 3 {
+		t.Errorf("Expected NumGoroutines = 3, got %d", settings.NumGoroutines)
+	}
+	fc := settings.FlowControlSettings
+	if fc.MaxOutstandingMessages != 500 || fc.MaxOutstandingBytes != 1<<20 || fc.LimitExceededBehavior != pubsub.FlowControlBlock {
+		t.Errorf("Unexpected flow control settings: %+v", fc)
+	}
+
+	// Zero values keep the client defaults
+	defaults, _ := Options{}.publishSettings()
+	if defaults.CountThreshold != pubsub.DefaultPublishSettings.CountThreshold {
+		t.Errorf("Expected default CountThreshold, got %d", defaults.CountThreshold)
+	}
+
+	if _, err := (Options{FlowControl: "unknown"}).publishSettings(); err == nil {
+		t.Error("Expected error for unknown flow control behavior")
+	}
+}
+
+func TestClient_PublishAsync(t *testing.T) {
+	client, srv := newTestClient(t, Options{Async: true, CountThreshold: 5})
+
+	for i := 0; i < 5; i++ {
+		if err := client.PublishAPILogEvent(context.Background(), newTestEvent("req")); err != nil {
+			t.Fatalf("PublishAPILogEvent() error = %v", err)
+		}
+	}
+
+	// Close flushes the batch and resolves every result
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if got := len(srv.Messages()); got != 5 {
+		t.Errorf("Expected 5 messages, got %d", got)
+	}
+
+	if err := client.PublishAPILogEvent(context.Background(), newTestEvent("req")); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Expected ErrClientClosed after close, got %v", err)
+	}
+}
+
+func TestClient_PublishAsyncReportsErrors(t *testing.T) {
+	var mu sync.Mutex
+	var failed []logger.APILogEvent
+
+	client, srv := newTestClient(t, Options{
+		Async: true,
+		OnAsyncError: func(event logger.APILogEvent, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failed = append(failed, event)
+		},
+	})
+	srv.SetAutoPublishResponse(false)
+	srv.AddPublishResponse(nil, status.Error(codes.FailedPrecondition, "injected failure"))
+
+	if err := client.PublishAPILogEvent(context.Background(), newTestEvent("req-1")); err != nil {
+		t.Fatalf("PublishAPILogEvent() error = %v", err)
+	}
+	client.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(failed) != 1 || failed[0].RequestID.String != "req-1" {
+		t.Errorf("Expected failed event to be reported, got %v", failed)
+	}
+}
+
+func TestClient_PublishesAttributes(t *testing.T) {
+	client, srv := newTestClient(t, Options{})
+
+	event := newTestEvent("req-1")
+	event.ResponseCode = 500
+	if err := client.PublishAPILogEvent(context.Background(), event); err != nil {
+		t.Fatalf("PublishAPILogEvent() error = %v", err)
+	}
+
+	messages := srv.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(messages))
+	}
+
+	attrs := messages[0].Attributes
+	if attrs["response_code_class"] != "5xx" || attrs["service"] != "test-service" {
+		t.Errorf("Unexpected attributes: %v", attrs)
+	}
+}
+
+func TestNew_RejectsUnknownAttributeField(t *testing.T) {
+	_, err := New(context.Background(), Options{
+		ProjectID:  testProjectID,
+		TopicName:  testTopicName,
+		Attributes: map[string]string{"foo": "bar"},
+	})
+	if err == nil {
+		t.Error("Expected error for unknown attribute field")
+	}
+}
+
+func TestClient_CompressesAndTruncates(t *testing.T) {
+	client, srv := newTestClient(t, Options{Compression: logger.EncodingGzip, MaxBodyBytes: 10})
+
+	event := newTestEvent("req-1")
+	event.ResponseBody = null.StringFrom(strings.Repeat("x", 100))
+	if err := client.PublishAPILogEvent(context.Background(), event); err != nil {
+		t.Fatalf("PublishAPILogEvent() error = %v", err)
+	}
+
+	messages := srv.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(messages))
+	}
+	if messages[0].Attributes[logger.ContentEncodingAttribute] != logger.EncodingGzip {
+		t.Errorf("Expected content-encoding = gzip, got %v", messages[0].Attributes)
+	}
+
+	published, err := logger.DecodeMessage(messages[0].Data, messages[0].Attributes)
+	if err != nil {
+		t.Fatalf("DecodeMessage() error = %v", err)
+	}
+	if !published.Truncated || published.ResponseBodySize != 100 || len(published.ResponseBody.String) != 10 {
+		t.Errorf("Expected truncated response body, got %+v", published)
+	}
+}
+
+func TestClient_RejectsOversizedMessage(t *testing.T) {
+	client, srv := newTestClient(t, Options{})
+
+	event := newTestEvent("req-1")
+	event.ResponseBody = null.StringFrom(strings.Repeat("x", maxMessageBytes))
+	err := client.PublishAPILogEvent(context.Background(), event)
+	if !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Expected ErrMessageTooLarge, got %v", err)
+	}
+	if len(srv.Messages()) != 0 {
+		t.Error("Expected oversized message not to be published")
+	}
+}
+
+func TestNew_RejectsUnknownCompression(t *testing.T) {
+	_, err := New(context.Background(), Options{
+		ProjectID:   testProjectID,
+		TopicName:   testTopicName,
+		Compression: "br",
+	})
+	if err == nil {
+		t.Error("Expected error for unknown compression")
+	}
+}
+
+func TestClient_PublishesWithEncoder(t *testing.T) {
+	client, srv := newTestClient(t, Options{Encoder: logger.AvroEncoder{}})
+
+	if err := client.PublishAPILogEvent(context.Background(), newTestEvent("req-1")); err != nil {
+		t.Fatalf("PublishAPILogEvent() error = %v", err)
+	}
+
+	messages := srv.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(messages))
+	}
+	if messages[0].Attributes[logger.ContentTypeAttribute] != logger.ContentTypeAvro {
+		t.Errorf("Expected content-type = %s, got %v", logger.ContentTypeAvro, messages[0].Attributes)
+	}
+
+	published, err := logger.DecodeMessage(messages[0].Data, messages[0].Attributes)
+	if err != nil {
+		t.Fatalf("DecodeMessage() error = %v", err)
+	}
+	if published.RequestID.String != "req-1" {
+		t.Errorf("Expected request ID = req-1, got %v", published.RequestID)
+	}
+}
+
+func TestClient_PublishesCloudEvents(t *testing.T) {
+	tests := []struct {
+		name            string
+		mode            string
+		wantContentType string
+		wantCEID        string
+	}{
+		{name: "structured", mode: logger.CloudEventsStructured, wantContentType: logger.ContentTypeCloudEvents},
+		{name: "binary", mode: logger.CloudEventsBinary, wantContentType: logger.ContentTypeJSON, wantCEID: "req-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, srv := newTestClient(t, Options{CloudEvents: tt.mode})
+
+			if err := client.PublishAPILogEvent(context.Background(), newTestEvent("req-1")); err != nil {
+				t.Fatalf("PublishAPILogEvent() error = %v", err)
+			}
+
+			messages := srv.Messages()
+			if len(messages) != 1 {
+				t.Fatalf("Expected 1 message, got %d", len(messages))
+			}
+			attrs := messages[0].Attributes
+			if attrs[logger.ContentTypeAttribute] != tt.wantContentType || attrs["ce-id"] != tt.wantCEID {
+				t.Errorf("Unexpected attributes: %v", attrs)
+			}
+
+			published, err := logger.DecodeMessage(messages[0].Data, attrs)
+			if err != nil {
+				t.Fatalf("DecodeMessage() error = %v", err)
+			}
+			if published.RequestID.String != "req-1" {
+				t.Errorf("Expected request ID = req-1, got %v", published.RequestID)
+			}
+		})
+	}
+}
+
+func TestClient_SignsMessages(t *testing.T) {
+	keys := logger.Keyring{"k1": []byte("secret")}
+	signer, err := logger.NewSigner(keys, "k1")
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	client, srv := newTestClient(t, Options{Signer: signer, Compression: logger.EncodingGzip})
+
+	if err := client.PublishAPILogEvent(context.Background(), newTestEvent("req-1")); err != nil {
+		t.Fatalf("PublishAPILogEvent() error = %v", err)
+	}
+
+	messages := srv.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(messages))
+	}
+	keyID, err := logger.VerifyMessage(messages[0].Data, messages[0].Attributes, keys)
+	if err != nil || keyID != "k1" {
+		t.Errorf("Expected signature verified with k1, got %q, %v", keyID, err)
+	}
+
+	// Changing how the data is decoded breaks the signature
+	messages[0].Attributes[logger.ContentEncodingAttribute] = logger.EncodingZstd
+	if _, err := logger.VerifyMessage(messages[0].Data, messages[0].Attributes, keys); !errors.Is(err, logger.ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for a changed attribute, got %v", err)
+	}
+}
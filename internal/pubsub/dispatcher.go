@@ -0,0 +1,329 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"api-pubsub-logger/pkg/logger"
+)
+
+var (
+	// ErrQueueFull is returned when an event is dropped because the dispatch queue is full
+	ErrQueueFull = errors.New("dispatch queue is full")
+	// ErrDispatcherClosed is returned when an event is published after the dispatcher was closed
+	ErrDispatcherClosed = errors.New("dispatcher is closed")
+)
+
+// flushPollInterval is how often Flush checks for pending events
+const flushPollInterval = 10 * time.Millisecond
+
+// OverflowPolicy controls what the Dispatcher does when its queue is full
+type OverflowPolicy int
+
+const (
+	// OverflowDropNewest drops the event being enqueued
+	OverflowDropNewest OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest queued event to make room for the new one
+	OverflowDropOldest
+	// OverflowBlock waits up to BlockTimeout for room in the queue before dropping the event
+	OverflowBlock
+)
+
+// ParseOverflowPolicy parses an overflow policy name (drop-newest, drop-oldest or block)
+func ParseOverflowPolicy(name string) (OverflowPolicy, error) {
+	switch strings.ToLower(name) {
+	case "", "drop-newest":
+		return OverflowDropNewest, nil
+	case "drop-oldest":
+		return OverflowDropOldest, nil
+	case "block":
+		return OverflowBlock, nil
+	default:
+		return 0, fmt.Errorf("unknown overflow policy %q", name)
+	}
+}
+
+// DispatcherOptions contains configuration options for the Dispatcher
+type DispatcherOptions struct {
+	QueueSize      int
+	Workers        int
+	Overflow       OverflowPolicy
+	BlockTimeout   time.Duration
+	PublishTimeout time.Duration
+	// OrderingKey partitions the queue between the workers so that events with the same
+	// non-empty key are published one at a time and in order (see OrderingKeyFromField)
+	OrderingKey func(logger.APILogEvent) string
+}
+
+// DispatcherStats is a snapshot of the Dispatcher counters
+type DispatcherStats struct {
+	Enqueued  int64
+	Published int64
+	Failed    int64
+	Dropped   int64
+}
+
+// Dispatcher publishes API log events through a bounded queue drained by a fixed pool of workers
+// With an ordering key, each worker drains its own partition of the queue
+type Dispatcher struct {
+	next   Publisher
+	opts   DispatcherOptions
+	queues []chan logger.APILogEvent
+	wg     sync.WaitGroup
+
+	// unkeyed spreads the events without an ordering key over the partitions
+	unkeyed atomic.Uint64
+
+	// mu guards closed and the queue channels against sends after close
+	mu     sync.RWMutex
+	closed bool
+
+	// ctx is canceled when Shutdown gives up on the events being published
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// abandonMu guards inflight and abandoned, so that an event being published when Shutdown
+	// gives up is counted as dropped and not also as published or failed
+	abandonMu sync.Mutex
+	inflight  int64
+	abandoned bool
+
+	// pending counts events that are queued or being published
+	pending   atomic.Int64
+	enqueued  atomic.Int64
+	published atomic.Int64
+	failed    atomic.Int64
+	dropped   atomic.Int64
+}
+
+// NewDispatcher creates a Dispatcher in front of next and starts its workers
+func NewDispatcher(next Publisher, opts DispatcherOptions) *Dispatcher {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1000
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+
+	d := &Dispatcher{
+		next: next,
+		opts: opts,
+	}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+
+	if opts.OrderingKey == nil {
+		d.queues = []chan logger.APILogEvent{make(chan logger.APILogEvent, opts.QueueSize)}
+	} else {
+		for i := 0; i < opts.Workers; i++ {
+			d.queues = append(d.queues, make(chan logger.APILogEvent, max(opts.QueueSize/opts.Workers, 1)))
+		}
+	}
+
+	d.wg.Add(opts.Workers)
+	for i := 0; i < opts.Workers; i++ {
+		go d.worker(d.queues[i%len(d.queues)])
+	}
+
+	return d
+}
+
+// PublishAPILogEvent enqueues an API log event without waiting for it to be published
+func (d *Dispatcher) PublishAPILogEvent(_ context.Context, event logger.APILogEvent) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		d.dropped.Add(1)
+		return ErrDispatcherClosed
+	}
+
+	queue := d.queueFor(event)
+	select {
+	case queue <- event:
+		d.accepted()
+		return nil
+	default:
+	}
+
+	switch d.opts.Overflow {
+	case OverflowDropOldest:
+		for {
+			select {
+			case queue <- event:
+				d.accepted()
+				return nil
+			default:
+			}
+			select {
+			case <-queue:
+				d.pending.Add(-1)
+				d.dropped.Add(1)
+			default:
+			}
+		}
+	case OverflowBlock:
+		timer := time.NewTimer(d.opts.BlockTimeout)
+		defer timer.Stop()
+		select {
+		case queue <- event:
+			d.accepted()
+			return nil
+		case <-timer.C:
+		}
+	}
+
+	d.dropped.Add(1)
+	return ErrQueueFull
+}
+
+// queueFor returns the queue of an event, the partition of its ordering key when there is one
+func (d *Dispatcher) queueFor(event logger.APILogEvent) chan logger.APILogEvent {
+	if len(d.queues) == 1 {
+		return d.queues[0]
+	}
+
+	key := d.opts.OrderingKey(event)
+	if key == "" {
+		return d.queues[d.unkeyed.Add(1)%uint64(len(d.queues))]
+	}
+	return d.queues[keyPartition(key, len(d.queues))]
+}
+
+// keyPartition returns the partition (out of n) of an ordering key
+func keyPartition(key string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+// accepted records an event that was added to a queue
+func (d *Dispatcher) accepted() {
+	d.pending.Add(1)
+	d.enqueued.Add(1)
+}
+
+// Stats returns a snapshot of the Dispatcher counters
+func (d *Dispatcher) Stats() DispatcherStats {
+	return DispatcherStats{
+		Enqueued:  d.enqueued.Load(),
+		Published: d.published.Load(),
+		Failed:    d.failed.Load(),
+		Dropped:   d.dropped.Load(),
+	}
+}
+
+// Flush waits until every queued event has been published or ctx is done
+func (d *Dispatcher) Flush(ctx context.Context) error {
+	ticker := time.NewTicker(flushPollInterval)
+	defer ticker.Stop()
+
+	for d.pending.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Shutdown stops accepting events and waits for the queue to drain or ctx to be done
+// Events still queued or being published when ctx is done are dropped and counted in Stats,
+// the publishes in flight are canceled but may still be running when Shutdown returns
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		for _, queue := range d.queues {
+			close(queue)
+		}
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.abandonMu.Lock()
+		d.abandoned = true
+		d.dropped.Add(d.inflight)
+		d.abandonMu.Unlock()
+		d.cancel()
+
+		// Discard whatever the workers did not get to in time
+		for _, queue := range d.queues {
+			for range queue {
+				d.pending.Add(-1)
+				d.dropped.Add(1)
+			}
+		}
+		return ctx.Err()
+	}
+}
+
+// Close drains the queue and closes the underlying publisher once every worker has returned,
+// after a Shutdown that gave up it only waits for the canceled publishes
+func (d *Dispatcher) Close() error {
+	if err := d.Shutdown(context.Background()); err != nil {
+		return err
+	}
+	return d.next.Close()
+}
+
+// worker publishes the events of a queue until it is closed
+func (d *Dispatcher) worker(queue chan logger.APILogEvent) {
+	defer d.wg.Done()
+	for event := range queue {
+		d.publish(event)
+		d.pending.Add(-1)
+	}
+}
+
+// publish sends a single event to the underlying publisher
+func (d *Dispatcher) publish(event logger.APILogEvent) {
+	d.abandonMu.Lock()
+	if d.abandoned {
+		d.abandonMu.Unlock()
+		d.dropped.Add(1)
+		return
+	}
+	d.inflight++
+	d.abandonMu.Unlock()
+
+	ctx := d.ctx
+	if d.opts.PublishTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.opts.PublishTimeout)
+		defer cancel()
+	}
+
+	err := d.next.PublishAPILogEvent(ctx, event)
+
+	d.abandonMu.Lock()
+	defer d.abandonMu.Unlock()
+	d.inflight--
+	if d.abandoned {
+		// Shutdown already counted the event as dropped
+		return
+	}
+	if err != nil {
+		d.failed.Add(1)
+		log.Printf("Failed to publish API log event: %v", err)
+		return
+	}
+	d.published.Add(1)
+}
@@ -0,0 +1,408 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"api-pubsub-logger/pkg/logger"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+// mockPublisher is a mock implementation of the Publisher interface for testing
+type mockPublisher struct {
+	mu              sync.Mutex
+	publishedEvents []logger.APILogEvent
+	publishError    error
+	gate            chan struct{}
+	closed          bool
+}
+
+func (m *mockPublisher) PublishAPILogEvent(ctx context.Context, event logger.APILogEvent) error {
+	if m.gate != nil {
+		<-m.gate
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.publishedEvents = append(m.publishedEvents, event)
+	return m.publishError
+}
+
+func (m *mockPublisher) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	return nil
+}
+
+func (m *mockPublisher) isClosed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.closed
+}
+
+func (m *mockPublisher) getEvents() []logger.APILogEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Return a copy to avoid race conditions
+	events := make([]logger.APILogEvent, len(m.publishedEvents))
+	copy(events, m.publishedEvents)
+	return events
+}
+
+// publisherFunc adapts a function to the Publisher interface for testing
+type publisherFunc func(ctx context.Context, event logger.APILogEvent) error
+
+func (f publisherFunc) PublishAPILogEvent(ctx context.Context, event logger.APILogEvent) error {
+	return f(ctx, event)
+}
+
+func (f publisherFunc) Close() error {
+	return nil
+}
+
+func newTestEvent(requestID string) logger.APILogEvent {
+	return logger.APILogEvent{
+		RequestID: null.StringFrom(requestID),
+		Service:   "test-service",
+		Method:    "GET",
+		URL:       "/v1/items",
+	}
+}
+
+func TestParseOverflowPolicy(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected OverflowPolicy
+		wantErr  bool
+	}{
+		{input: "", expected: OverflowDropNewest},
+		{input: "drop-newest", expected: OverflowDropNewest},
+		{input: "DROP-OLDEST", expected: OverflowDropOldest},
+		{input: "block", expected: OverflowBlock},
+		{input: "unknown", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			policy, err := ParseOverflowPolicy(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseOverflowPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if policy != tt.expected {
+				t.Errorf("ParseOverflowPolicy() = %v, want %v", policy, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDispatcher_PublishesAllEvents(t *testing.T) {
+	mock := &mockPublisher{}
+	d := NewDispatcher(mock, DispatcherOptions{QueueSize: 10, Workers: 2})
+
+	for i := 0; i < 5; i++ {
+		if err := d.PublishAPILogEvent(context.Background(), newTestEvent("req")); err != nil {
+			t.Fatalf("PublishAPILogEvent() error = %v", err)
+		}
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if got := len(mock.getEvents()); got != 5 {
+		t.Errorf("Expected 5 published events, got %d", got)
+	}
+
+	stats := d.Stats()
+	if stats.Enqueued != 5 || stats.Published != 5 || stats.Dropped != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	if !mock.closed {
+		t.Error("Expected underlying publisher to be closed")
+	}
+}
+
+func TestDispatcher_CountsFailures(t *testing.T) {
+	mock := &mockPublisher{publishError: errors.New("publish failed")}
+	d := NewDispatcher(mock, DispatcherOptions{QueueSize: 10, Workers: 1})
+
+	d.PublishAPILogEvent(context.Background(), newTestEvent("req-1"))
+	d.Close()
+
+	stats := d.Stats()
+	if stats.Failed != 1 || stats.Published != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestDispatcher_OverflowPolicies(t *testing.T) {
+	tests := []struct {
+		name         string
+		overflow     OverflowPolicy
+		expectedErr  error
+		expectedLast string
+	}{
+		{
+			name:         "drop newest rejects the new event",
+			overflow:     OverflowDropNewest,
+			expectedErr:  ErrQueueFull,
+			expectedLast: "req-2",
+		},
+		{
+			name:         "drop oldest evicts a queued event",
+			overflow:     OverflowDropOldest,
+			expectedErr:  nil,
+			expectedLast: "req-3",
+		},
+		{
+			name:         "block times out and drops the new event",
+			overflow:     OverflowBlock,
+			expectedErr:  ErrQueueFull,
+			expectedLast: "req-2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockPublisher{gate: make(chan struct{})}
+			d := NewDispatcher(mock, DispatcherOptions{
+				QueueSize:    1,
+				Workers:      1,
+				Overflow:     tt.overflow,
+				BlockTimeout: 10 * time.Millisecond,
+			})
+
+			// The first event is picked up by the worker, which then blocks on the gate
+			d.PublishAPILogEvent(context.Background(), newTestEvent("req-1"))
+			waitFor(t, func() bool { return len(d.queues[0]) == 0 })
+
+			// The second event fills the queue
+			if err := d.PublishAPILogEvent(context.Background(), newTestEvent("req-2")); err != nil {
+				t.Fatalf("PublishAPILogEvent() error = %v", err)
+			}
+
+			// The third event overflows
+			err := d.PublishAPILogEvent(context.Background(), newTestEvent("req-3"))
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+
+			close(mock.gate)
+			d.Close()
+
+			events := mock.getEvents()
+			if len(events) != 2 {
+				t.Fatalf("Expected 2 published events, got %d", len(events))
+			}
+			if last := events[1].RequestID.String; last != tt.expectedLast {
+				t.Errorf("Expected last event = %v, got %v", tt.expectedLast, last)
+			}
+
+			if dropped := d.Stats().Dropped; dropped != 1 {
+				t.Errorf("Expected 1 dropped event, got %d", dropped)
+			}
+		})
+	}
+}
+
+func TestDispatcher_RejectsAfterClose(t *testing.T) {
+	d := NewDispatcher(&mockPublisher{}, DispatcherOptions{})
+	d.Close()
+
+	err := d.PublishAPILogEvent(context.Background(), newTestEvent("req-1"))
+	if !errors.Is(err, ErrDispatcherClosed) {
+		t.Errorf("Expected ErrDispatcherClosed, got %v", err)
+	}
+}
+
+func TestDispatcher_FlushWaitsForPendingEvents(t *testing.T) {
+	mock := &mockPublisher{gate: make(chan struct{})}
+	d := NewDispatcher(mock, DispatcherOptions{QueueSize: 10, Workers: 1})
+
+	for i := 0; i < 3; i++ {
+		d.PublishAPILogEvent(context.Background(), newTestEvent("req"))
+	}
+
+	// Flush gives up while the publisher is blocked
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := d.Flush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+
+	close(mock.gate)
+	if err := d.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	if got := len(mock.getEvents()); got != 3 {
+		t.Errorf("Expected 3 published events after flush, got %d", got)
+	}
+}
+
+func TestDispatcher_ShutdownDrainsQueue(t *testing.T) {
+	mock := &mockPublisher{}
+	d := NewDispatcher(mock, DispatcherOptions{QueueSize: 10, Workers: 2})
+
+	for i := 0; i < 5; i++ {
+		d.PublishAPILogEvent(context.Background(), newTestEvent("req"))
+	}
+
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	if got := len(mock.getEvents()); got != 5 {
+		t.Errorf("Expected 5 published events, got %d", got)
+	}
+
+	if mock.closed {
+		t.Error("Shutdown should not close the underlying publisher")
+	}
+}
+
+func TestDispatcher_ShutdownDropsEventsPastDeadline(t *testing.T) {
+	mock := &mockPublisher{gate: make(chan struct{})}
+	d := NewDispatcher(mock, DispatcherOptions{QueueSize: 10, Workers: 1})
+
+	for i := 0; i < 4; i++ {
+		d.PublishAPILogEvent(context.Background(), newTestEvent("req"))
+	}
+	// Wait for the worker to pick up the first event and block on the gate
+	waitFor(t, func() bool { return len(d.queues[0]) == 3 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := d.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+
+	// The event being published counts as dropped along with the queued ones
+	if dropped := d.Stats().Dropped; dropped != 4 {
+		t.Errorf("Expected 4 dropped events, got %d", dropped)
+	}
+
+	// Close waits for the publish in flight before closing the underlying publisher
+	closed := make(chan struct{})
+	go func() {
+		d.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned while an event was being published")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if mock.isClosed() {
+		t.Error("Expected underlying publisher to stay open while an event is being published")
+	}
+
+	close(mock.gate)
+	<-closed
+	if !mock.isClosed() {
+		t.Error("Expected underlying publisher to be closed")
+	}
+
+	stats := d.Stats()
+	if stats.Published != 0 || stats.Failed != 0 || stats.Dropped != 4 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestDispatcher_ShutdownCancelsEventsPastDeadline(t *testing.T) {
+	started := make(chan struct{})
+	canceled := make(chan error, 1)
+	next := publisherFunc(func(ctx context.Context, event logger.APILogEvent) error {
+		close(started)
+		<-ctx.Done()
+		canceled <- ctx.Err()
+		return ctx.Err()
+	})
+	d := NewDispatcher(next, DispatcherOptions{QueueSize: 10, Workers: 1})
+
+	d.PublishAPILogEvent(context.Background(), newTestEvent("req-1"))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := d.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+
+	if err := <-canceled; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the publish in flight to be canceled, got %v", err)
+	}
+	d.Close()
+
+	stats := d.Stats()
+	if stats.Failed != 0 || stats.Dropped != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestDispatcher_KeepsOrderingKeyOrder(t *testing.T) {
+	var mu sync.Mutex
+	published := map[string][]int{}
+	failed := map[string]bool{}
+
+	// Every other event fails once, its retry must still come before the next event of its user
+	next := publisherFunc(func(ctx context.Context, event logger.APILogEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		seq, _ := strconv.Atoi(event.RequestID.String)
+		if seq%2 == 0 && !failed[event.RequestID.String] {
+			failed[event.RequestID.String] = true
+			return errors.New("transient failure")
+		}
+		published[event.UserID.String] = append(published[event.UserID.String], seq)
+		return nil
+	})
+	retry := NewRetryPublisher(next, RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		Retryable:   func(error) bool { return true },
+	})
+	d := NewDispatcher(retry, DispatcherOptions{
+		QueueSize:   100,
+		Workers:     4,
+		OrderingKey: func(event logger.APILogEvent) string { return event.UserID.String },
+	})
+
+	for i := 0; i < 60; i++ {
+		event := newTestEvent(strconv.Itoa(i))
+		event.UserID = null.StringFrom(fmt.Sprintf("user-%d", i%5))
+		if err := d.PublishAPILogEvent(context.Background(), event); err != nil {
+			t.Fatalf("PublishAPILogEvent() error = %v", err)
+		}
+	}
+	d.Close()
+
+	if len(published) != 5 {
+		t.Fatalf("Expected events of 5 users, got %d", len(published))
+	}
+	for user, seqs := range published {
+		if len(seqs) != 12 || !sort.IntsAreSorted(seqs) {
+			t.Errorf("Expected 12 events of %s in order, got %v", user, seqs)
+		}
+	}
+}
+
+// waitFor polls cond until it returns true or the test times out
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,39 @@
+package pubsub
+
+import (
+	"context"
+
+	"api-pubsub-logger/pkg/logger"
+)
+
+// EncryptingPublisher is a Publisher decorator encrypting the request and response bodies of events
+// Placed in front of the spool, bodies are never written to disk in the clear
+type EncryptingPublisher struct {
+	next         Publisher
+	encrypter    *logger.BodyEncrypter
+	maxBodyBytes int
+}
+
+// NewEncryptingPublisher wraps next with body encryption
+// Bodies are truncated to maxBodyBytes first since encrypted bodies cannot be truncated, zero disables it
+func NewEncryptingPublisher(next Publisher, encrypter *logger.BodyEncrypter, maxBodyBytes int) *EncryptingPublisher {
+	return &EncryptingPublisher{
+		next:         next,
+		encrypter:    encrypter,
+		maxBodyBytes: maxBodyBytes,
+	}
+}
+
+// PublishAPILogEvent encrypts the bodies of an API log event and publishes it
+func (p *EncryptingPublisher) PublishAPILogEvent(ctx context.Context, event logger.APILogEvent) error {
+	event, err := p.encrypter.EncryptBodies(event.TruncateBodies(p.maxBodyBytes))
+	if err != nil {
+		return err
+	}
+	return p.next.PublishAPILogEvent(ctx, event)
+}
+
+// Close closes the underlying publisher
+func (p *EncryptingPublisher) Close() error {
+	return p.next.Close()
+}
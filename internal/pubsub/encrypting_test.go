@@ -0,0 +1,49 @@
+package pubsub
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"api-pubsub-logger/pkg/logger"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestEncryptingPublisher_PublishAPILogEvent(t *testing.T) {
+	keys := logger.Keyring{"dk-1": []byte("0123456789abcdef")}
+	encrypter, err := logger.NewBodyEncrypter(keys, "dk-1")
+	if err != nil {
+		t.Fatalf("NewBodyEncrypter() error = %v", err)
+	}
+
+	mock := &mockPublisher{}
+	p := NewEncryptingPublisher(mock, encrypter, 8)
+
+	event := newTestEvent("req-1")
+	event.ResponseBody = null.StringFrom(`{"email":"jane@example.com"}`)
+	if err := p.PublishAPILogEvent(context.Background(), event); err != nil {
+		t.Fatalf("PublishAPILogEvent() error = %v", err)
+	}
+
+	events := mock.getEvents()
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events))
+	}
+	published := events[0]
+	if published.EncryptionKeyID != "dk-1" || strings.Contains(published.ResponseBody.String, "jane") {
+		t.Errorf("Expected encrypted response body, got %+v", published)
+	}
+	if published.RequestID.String != "req-1" {
+		t.Errorf("Expected request ID to stay in the clear, got %v", published.RequestID)
+	}
+
+	// Bodies are truncated before being encrypted
+	decrypted, err := logger.DecryptBodies(published, keys)
+	if err != nil {
+		t.Fatalf("DecryptBodies() error = %v", err)
+	}
+	if decrypted.ResponseBody.String != `{"email"` || !decrypted.Truncated {
+		t.Errorf("Expected truncated response body, got %+v", decrypted)
+	}
+}
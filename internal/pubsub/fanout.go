@@ -0,0 +1,119 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"api-pubsub-logger/pkg/logger"
+)
+
+// NamedPublisher is a publisher identified by a name in FanoutPublisher stats
+type NamedPublisher struct {
+	Name      string
+	Publisher Publisher
+}
+
+// SinkStats is a snapshot of the counters of a single fan-out sink
+type SinkStats struct {
+	Published int64
+	Failed    int64
+	Dropped   int64
+}
+
+// FanoutPublisher publishes every API log event to several publishers
+// Each sink has its own Dispatcher, so a failing or slow sink does not delay the others
+type FanoutPublisher struct {
+	sinks []*fanoutSink
+}
+
+// fanoutSink is a publisher with its own queue and workers
+type fanoutSink struct {
+	name       string
+	dispatcher *Dispatcher
+}
+
+// NewFanoutPublisher creates a publisher writing to all sinks, each through a Dispatcher created with opts
+// opts.PublishTimeout bounds each sink publish
+func NewFanoutPublisher(opts DispatcherOptions, sinks ...NamedPublisher) *FanoutPublisher {
+	p := &FanoutPublisher{}
+	for _, sink := range sinks {
+		p.sinks = append(p.sinks, &fanoutSink{
+			name:       sink.Name,
+			dispatcher: NewDispatcher(namedSinkPublisher(sink), opts),
+		})
+	}
+	return p
+}
+
+// namedSinkPublisher prefixes the errors of a sink with its name in the Dispatcher logs
+type namedSinkPublisher NamedPublisher
+
+func (p namedSinkPublisher) PublishAPILogEvent(ctx context.Context, event logger.APILogEvent) error {
+	if err := p.Publisher.PublishAPILogEvent(ctx, event); err != nil {
+		return fmt.Errorf("%s sink: %w", p.Name, err)
+	}
+	return nil
+}
+
+func (p namedSinkPublisher) Close() error {
+	return p.Publisher.Close()
+}
+
+// PublishAPILogEvent enqueues an API log event for every sink without waiting for it to be published
+// The returned error joins the errors of the sinks that dropped the event (e.g. ErrQueueFull),
+// publish failures are handled by each sink (e.g. spooled) and counted in Stats
+func (p *FanoutPublisher) PublishAPILogEvent(ctx context.Context, event logger.APILogEvent) error {
+	var errs []error
+	for _, sink := range p.sinks {
+		if err := sink.dispatcher.PublishAPILogEvent(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Stats returns a snapshot of the counters of every sink, keyed by sink name
+func (p *FanoutPublisher) Stats() map[string]SinkStats {
+	stats := make(map[string]SinkStats, len(p.sinks))
+	for _, sink := range p.sinks {
+		dispatcherStats := sink.dispatcher.Stats()
+		stats[sink.name] = SinkStats{
+			Published: dispatcherStats.Published,
+			Failed:    dispatcherStats.Failed,
+			Dropped:   dispatcherStats.Dropped,
+		}
+	}
+	return stats
+}
+
+// Shutdown stops accepting events and waits for the queue of every sink to drain or ctx to be done
+func (p *FanoutPublisher) Shutdown(ctx context.Context) error {
+	errs := make([]error, len(p.sinks))
+
+	var wg sync.WaitGroup
+	for i, sink := range p.sinks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sink.dispatcher.Shutdown(ctx); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", sink.name, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// Close drains the queue of every sink and closes the sinks
+func (p *FanoutPublisher) Close() error {
+	var errs []error
+	for _, sink := range p.sinks {
+		if err := sink.dispatcher.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,169 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"api-pubsub-logger/pkg/logger"
+)
+
+// slowPublisher blocks until its context is done
+type slowPublisher struct{}
+
+func (slowPublisher) PublishAPILogEvent(ctx context.Context, event logger.APILogEvent) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (slowPublisher) Close() error {
+	return nil
+}
+
+func TestFanoutPublisher_PublishesToAllSinks(t *testing.T) {
+	first := &mockPublisher{}
+	second := &mockPublisher{}
+	p := NewFanoutPublisher(DispatcherOptions{},
+		NamedPublisher{Name: "first", Publisher: first},
+		NamedPublisher{Name: "second", Publisher: second},
+	)
+
+	if err := p.PublishAPILogEvent(context.Background(), newTestEvent("req-1")); err != nil {
+		t.Fatalf("PublishAPILogEvent() error = %v", err)
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if len(first.getEvents()) != 1 || len(second.getEvents()) != 1 {
+		t.Error("Expected event to be published to both sinks")
+	}
+	if !first.isClosed() || !second.isClosed() {
+		t.Error("Expected all sinks to be closed")
+	}
+}
+
+func TestFanoutPublisher_IsolatesFailures(t *testing.T) {
+	healthy := &mockPublisher{}
+	failing := &mockPublisher{publishError: errors.New("sink down")}
+	p := NewFanoutPublisher(DispatcherOptions{},
+		NamedPublisher{Name: "healthy", Publisher: healthy},
+		NamedPublisher{Name: "failing", Publisher: failing},
+	)
+
+	// Sink failures are handled in the background and not reported to the caller
+	for i := 0; i < 2; i++ {
+		if err := p.PublishAPILogEvent(context.Background(), newTestEvent("req")); err != nil {
+			t.Errorf("PublishAPILogEvent() error = %v", err)
+		}
+	}
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	if len(healthy.getEvents()) != 2 {
+		t.Errorf("Expected healthy sink to receive 2 events, got %d", len(healthy.getEvents()))
+	}
+
+	stats := p.Stats()
+	if stats["healthy"] != (SinkStats{Published: 2}) {
+		t.Errorf("Unexpected healthy sink stats: %+v", stats["healthy"])
+	}
+	if stats["failing"] != (SinkStats{Failed: 2}) {
+		t.Errorf("Unexpected failing sink stats: %+v", stats["failing"])
+	}
+}
+
+func TestFanoutPublisher_BoundsSlowSinks(t *testing.T) {
+	fast := &mockPublisher{}
+	p := NewFanoutPublisher(DispatcherOptions{PublishTimeout: 20 * time.Millisecond},
+		NamedPublisher{Name: "fast", Publisher: fast},
+		NamedPublisher{Name: "slow", Publisher: slowPublisher{}},
+	)
+
+	if err := p.PublishAPILogEvent(context.Background(), newTestEvent("req-1")); err != nil {
+		t.Fatalf("PublishAPILogEvent() error = %v", err)
+	}
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	if len(fast.getEvents()) != 1 {
+		t.Error("Expected fast sink to receive the event")
+	}
+	if stats := p.Stats(); stats["slow"] != (SinkStats{Failed: 1}) {
+		t.Errorf("Expected slow sink publish to time out, got %+v", stats["slow"])
+	}
+}
+
+func TestFanoutPublisher_SlowSinkDoesNotBlockDispatcher(t *testing.T) {
+	fast := &mockPublisher{}
+	slow := &mockPublisher{gate: make(chan struct{})}
+	fanout := NewFanoutPublisher(DispatcherOptions{QueueSize: 100},
+		NamedPublisher{Name: "fast", Publisher: fast},
+		NamedPublisher{Name: "slow", Publisher: slow},
+	)
+	d := NewDispatcher(fanout, DispatcherOptions{})
+
+	for i := 0; i < 20; i++ {
+		if err := d.PublishAPILogEvent(context.Background(), newTestEvent("req")); err != nil {
+			t.Fatalf("PublishAPILogEvent() error = %v", err)
+		}
+	}
+
+	// The shared workers only enqueue for each sink, so the fast sink gets every event
+	// while the slow one is stuck
+	waitFor(t, func() bool { return len(fast.getEvents()) == 20 })
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := d.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if len(slow.getEvents()) != 0 {
+		t.Errorf("Expected slow sink to be stuck, got %d events", len(slow.getEvents()))
+	}
+
+	close(slow.gate)
+	d.Shutdown(context.Background())
+	fanout.Shutdown(context.Background())
+	d.Close()
+
+	if len(slow.getEvents()) != 20 {
+		t.Errorf("Expected slow sink to catch up with 20 events, got %d", len(slow.getEvents()))
+	}
+}
+
+func TestFanoutPublisher_SpoolsPerSink(t *testing.T) {
+	healthy := &mockPublisher{}
+	failing := &mockPublisher{publishError: errors.New("sink down")}
+	spool, err := OpenSpool(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatalf("OpenSpool() error = %v", err)
+	}
+	failingSpool := NewSpoolPublisher(failing, spool, 0, nil)
+
+	p := NewFanoutPublisher(DispatcherOptions{},
+		NamedPublisher{Name: "healthy", Publisher: healthy},
+		NamedPublisher{Name: "failing", Publisher: failingSpool},
+	)
+	defer p.Close()
+
+	p.PublishAPILogEvent(context.Background(), newTestEvent("req-1"))
+	p.Shutdown(context.Background())
+
+	// Replaying the failing sink's spool does not resend the event to the healthy sink
+	failing.mu.Lock()
+	failing.publishError = nil
+	failing.mu.Unlock()
+	if n, err := failingSpool.Replay(context.Background()); err != nil || n != 1 {
+		t.Fatalf("Expected 1 replayed event, got %d (err = %v)", n, err)
+	}
+
+	if len(healthy.getEvents()) != 1 {
+		t.Errorf("Expected healthy sink to receive the event once, got %d", len(healthy.getEvents()))
+	}
+	if len(failing.getEvents()) != 2 {
+		t.Errorf("Expected failing sink to receive the event and its replay, got %d", len(failing.getEvents()))
+	}
+}
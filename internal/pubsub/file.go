@@ -0,0 +1,70 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+
+	"api-pubsub-logger/pkg/logger"
+)
+
+// filePrefix is the file name prefix of API log files
+const filePrefix = "api-log"
+
+// FilePublisher writes API log events to rotating NDJSON files
+type FilePublisher struct {
+	writer   *rotatingWriter
+	maxFiles int
+}
+
+// NewFilePublisher creates a publisher writing to dir
+// Files are rotated at maxFileBytes and only the newest maxFiles sealed files are kept (all when zero)
+func NewFilePublisher(dir string, maxFileBytes int64, maxFiles int) (*FilePublisher, error) {
+	writer, err := newRotatingWriter(dir, filePrefix, maxFileBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &FilePublisher{
+		writer:   writer,
+		maxFiles: maxFiles,
+	}
+	writer.onRotate = p.prune
+
+	return p, nil
+}
+
+// PublishAPILogEvent appends an API log event to the current file
+func (p *FilePublisher) PublishAPILogEvent(_ context.Context, event logger.APILogEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return p.writer.writeLine(data)
+}
+
+// Close closes the current file
+func (p *FilePublisher) Close() error {
+	return p.writer.close()
+}
+
+// prune removes the oldest sealed files beyond maxFiles
+func (p *FilePublisher) prune() {
+	if p.maxFiles <= 0 {
+		return
+	}
+
+	segments, err := p.writer.segments()
+	if err != nil {
+		log.Printf("Failed to list API log files: %v", err)
+		return
+	}
+
+	for len(segments) > p.maxFiles {
+		if err := os.Remove(segments[0]); err != nil {
+			log.Printf("Failed to remove API log file: %v", err)
+		}
+		segments = segments[1:]
+	}
+}
@@ -0,0 +1,83 @@
+package pubsub
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"api-pubsub-logger/pkg/logger"
+)
+
+func TestFilePublisher_WritesNDJSON(t *testing.T) {
+	dir := t.TempDir()
+	p, err := NewFilePublisher(dir, 0, 0)
+	if err != nil {
+		t.Fatalf("NewFilePublisher() error = %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := p.PublishAPILogEvent(context.Background(), newTestEvent(fmt.Sprintf("req-%d", i))); err != nil {
+			t.Fatalf("PublishAPILogEvent() error = %v", err)
+		}
+	}
+	p.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.ndjson"))
+	if len(files) != 1 {
+		t.Fatalf("Expected 1 file, got %d", len(files))
+	}
+
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+
+	var events []logger.APILogEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event logger.APILogEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("Failed to decode line: %v", err)
+		}
+		events = append(events, event)
+	}
+
+	if len(events) != 3 || events[2].RequestID.String != "req-2" {
+		t.Errorf("Unexpected events in file: %v", events)
+	}
+}
+
+func TestFilePublisher_RotatesAndPrunes(t *testing.T) {
+	dir := t.TempDir()
+	// Every event is larger than the cap, so each one lands in its own file
+	p, err := NewFilePublisher(dir, 10, 2)
+	if err != nil {
+		t.Fatalf("NewFilePublisher() error = %v", err)
+	}
+	defer p.Close()
+
+	for i := 0; i < 5; i++ {
+		p.PublishAPILogEvent(context.Background(), newTestEvent(fmt.Sprintf("req-%d", i)))
+	}
+
+	// Two sealed files are kept next to the active one
+	files, _ := filepath.Glob(filepath.Join(dir, "*.ndjson"))
+	if len(files) != 3 {
+		t.Fatalf("Expected 3 files, got %d", len(files))
+	}
+
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	var oldest logger.APILogEvent
+	json.Unmarshal(data, &oldest)
+	if oldest.RequestID.String != "req-2" {
+		t.Errorf("Expected oldest kept event = req-2, got %v", oldest.RequestID.String)
+	}
+}
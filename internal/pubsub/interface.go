@@ -1,13 +1,6 @@
 package pubsub
 
-import (
-	"context"
-
-	"api-pubsub-logger/pkg/logger"
-)
+import "api-pubsub-logger/pkg/apilog"
 
 // Publisher defines the interface for publishing API log events
-type Publisher interface {
-	PublishAPILogEvent(ctx context.Context, event logger.APILogEvent) error
-	Close() error
-}
+type Publisher = apilog.Publisher
@@ -0,0 +1,145 @@
+package pubsub
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// segmentExt is the file extension of NDJSON segment files
+const segmentExt = ".ndjson"
+
+// rotatingWriter appends NDJSON lines to size-capped segment files in a directory
+type rotatingWriter struct {
+	dir             string
+	prefix          string
+	maxSegmentBytes int64
+	// onRotate is called after a segment is sealed because it reached maxSegmentBytes
+	onRotate func()
+	// sync flushes every line to disk before writeLine returns
+	sync bool
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	seq  int
+}
+
+// newRotatingWriter creates the directory if needed and returns a writer for it
+func newRotatingWriter(dir, prefix string, maxSegmentBytes int64) (*rotatingWriter, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &rotatingWriter{
+		dir:             dir,
+		prefix:          prefix,
+		maxSegmentBytes: maxSegmentBytes,
+	}, nil
+}
+
+// writeLine appends line and a trailing newline, rotating first if the segment would exceed its cap
+func (w *rotatingWriter) writeLine(line []byte) error {
+	rotated, err := w.write(line)
+	if rotated && w.onRotate != nil {
+		w.onRotate()
+	}
+	return err
+}
+
+func (w *rotatingWriter) write(line []byte) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	rotated := false
+	if w.file != nil && w.maxSegmentBytes > 0 && w.size+int64(len(line))+1 > w.maxSegmentBytes {
+		if err := w.closeSegment(); err != nil {
+			return false, err
+		}
+		rotated = true
+	}
+
+	if w.file == nil {
+		if err := w.openSegment(); err != nil {
+			return rotated, err
+		}
+	}
+
+	n, err := w.file.Write(append(line, '\n'))
+	w.size += int64(n)
+	if err == nil && w.sync {
+		err = w.file.Sync()
+	}
+	return rotated, err
+}
+
+// rotate seals the active segment so the next write starts a new one
+func (w *rotatingWriter) rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.closeSegment()
+}
+
+// segments returns the sealed segment files, oldest first
+func (w *rotatingWriter) segments() ([]string, error) {
+	w.mu.Lock()
+	active := ""
+	if w.file != nil {
+		active = w.file.Name()
+	}
+	w.mu.Unlock()
+
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, w.prefix+"-") || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		path := filepath.Join(w.dir, name)
+		if path != active {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// close closes the active segment
+func (w *rotatingWriter) close() error {
+	return w.rotate()
+}
+
+func (w *rotatingWriter) openSegment() error {
+	w.seq++
+	name := fmt.Sprintf("%s-%020d-%06d%s", w.prefix, time.Now().UnixNano(), w.seq, segmentExt)
+	file, err := os.OpenFile(filepath.Join(w.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.size = 0
+	if w.sync {
+		// Persist the directory entry of the new segment as well
+		return syncDir(w.dir)
+	}
+	return nil
+}
+
+func (w *rotatingWriter) closeSegment() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	w.size = 0
+	return err
+}
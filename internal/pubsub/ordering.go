@@ -0,0 +1,26 @@
+package pubsub
+
+import (
+	"fmt"
+
+	"api-pubsub-logger/pkg/logger"
+)
+
+// orderingKeyFields are the event fields that can be used as ordering keys
+var orderingKeyFields = map[string]struct{}{
+	"user_id":    {},
+	"request_id": {},
+	"service":    {},
+}
+
+// OrderingKeyFromField returns an ordering key function using the given event field
+// (user_id, request_id or service), or nil when field is empty
+func OrderingKeyFromField(field string) (func(logger.APILogEvent) string, error) {
+	if field == "" {
+		return nil, nil
+	}
+	if _, ok := orderingKeyFields[field]; !ok {
+		return nil, fmt.Errorf("unknown ordering key field %q", field)
+	}
+	return attributeFields[field], nil
+}
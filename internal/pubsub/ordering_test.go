@@ -0,0 +1,92 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+
+	"api-pubsub-logger/pkg/logger"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestOrderingKeyFromField(t *testing.T) {
+	event := logger.APILogEvent{
+		RequestID: null.StringFrom("req-1"),
+		UserID:    null.StringFrom("user-1"),
+		Service:   "test-service",
+	}
+
+	tests := []struct {
+		field    string
+		expected string
+		wantErr  bool
+		wantNil  bool
+	}{
+		{field: "user_id", expected: "user-1"},
+		{field: "request_id", expected: "req-1"},
+		{field: "service", expected: "test-service"},
+		{field: "", wantNil: true},
+		{field: "method", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			keyFn, err := OrderingKeyFromField(tt.field)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OrderingKeyFromField() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.wantNil {
+				if keyFn != nil {
+					t.Error("Expected nil ordering key function")
+				}
+				return
+			}
+			if got := keyFn(event); got != tt.expected {
+				t.Errorf("ordering key = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestClient_PublishesOrderingKey(t *testing.T) {
+	keyFn, _ := OrderingKeyFromField("user_id")
+	client, srv := newTestClient(t, Options{OrderingKey: keyFn})
+
+	event := newTestEvent("req-1")
+	event.UserID = null.StringFrom("user-1")
+	if err := client.PublishAPILogEvent(context.Background(), event); err != nil {
+		t.Fatalf("PublishAPILogEvent() error = %v", err)
+	}
+
+	messages := srv.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(messages))
+	}
+	if messages[0].OrderingKey != "user-1" {
+		t.Errorf("Expected ordering key = user-1, got %v", messages[0].OrderingKey)
+	}
+}
+
+func TestClient_ResumesOrderingKeyAfterError(t *testing.T) {
+	keyFn, _ := OrderingKeyFromField("user_id")
+	client, srv := newTestClient(t, Options{OrderingKey: keyFn})
+	srv.SetAutoPublishResponse(false)
+	srv.AddPublishResponse(nil, status.Error(codes.FailedPrecondition, "injected failure"))
+
+	event := newTestEvent("req-1")
+	event.UserID = null.StringFrom("user-1")
+	if err := client.PublishAPILogEvent(context.Background(), event); err == nil {
+		t.Fatal("Expected injected publish error")
+	}
+
+	// Without resuming, the key would stay paused and this publish would fail
+	srv.SetAutoPublishResponse(true)
+	if err := client.PublishAPILogEvent(context.Background(), event); err != nil {
+		t.Fatalf("Expected publish to succeed after resume, got %v", err)
+	}
+}
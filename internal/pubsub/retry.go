@@ -0,0 +1,124 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"api-pubsub-logger/pkg/logger"
+
+	"cloud.google.com/go/pubsub"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// retryableCodes are the gRPC codes treated as transient by IsRetryable
+var retryableCodes = map[codes.Code]struct{}{
+	codes.Unavailable:       {},
+	codes.DeadlineExceeded:  {},
+	codes.ResourceExhausted: {},
+	codes.Aborted:           {},
+	codes.Internal:          {},
+}
+
+// RetryPolicy controls how failed publishes are retried
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// Jitter is the fraction (0 to 1) of each delay that is randomized
+	Jitter float64
+	// Retryable classifies errors as transient, IsRetryable is used when nil
+	Retryable func(error) bool
+}
+
+// DefaultRetryPolicy returns the retry policy used when none is configured
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+		Jitter:      0.2,
+	}
+}
+
+// IsRetryable reports whether err is a transient Pub/Sub error worth retrying
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	// Publishing for an ordering key is paused until the failed publish is resumed
+	var paused pubsub.ErrPublishingPaused
+	if errors.As(err, &paused) {
+		return true
+	}
+	_, ok := retryableCodes[status.Code(err)]
+	return ok
+}
+
+// backoff returns the delay before the given retry attempt (starting at 1)
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		delay -= time.Duration(p.Jitter * rand.Float64() * float64(delay))
+	}
+	return delay
+}
+
+// RetryPublisher is a Publisher decorator retrying transient failures with exponential backoff
+type RetryPublisher struct {
+	next   Publisher
+	policy RetryPolicy
+}
+
+// NewRetryPublisher wraps next with the given retry policy
+func NewRetryPublisher(next Publisher, policy RetryPolicy) *RetryPublisher {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 1
+	}
+	if policy.Retryable == nil {
+		policy.Retryable = IsRetryable
+	}
+
+	return &RetryPublisher{
+		next:   next,
+		policy: policy,
+	}
+}
+
+// PublishAPILogEvent publishes an API log event, retrying transient failures
+func (p *RetryPublisher) PublishAPILogEvent(ctx context.Context, event logger.APILogEvent) error {
+	for attempt := 1; ; attempt++ {
+		err := p.next.PublishAPILogEvent(ctx, event)
+		if err == nil || attempt >= p.policy.MaxAttempts || !p.policy.Retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		delay := p.policy.backoff(attempt)
+		log.Printf("Retrying API log event in %v (attempt %d/%d): %v", delay, attempt+1, p.policy.MaxAttempts, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// Close closes the underlying publisher
+func (p *RetryPublisher) Close() error {
+	return p.next.Close()
+}
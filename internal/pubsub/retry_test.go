@@ -0,0 +1,193 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"api-pubsub-logger/pkg/logger"
+
+	"cloud.google.com/go/pubsub"
+	pb "cloud.google.com/go/pubsub/apiv1/pubsubpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// flakyPublisher fails with the queued errors before succeeding
+type flakyPublisher struct {
+	errs     []error
+	attempts int
+}
+
+func (f *flakyPublisher) PublishAPILogEvent(ctx context.Context, event logger.APILogEvent) error {
+	f.attempts++
+	if len(f.errs) == 0 {
+		return nil
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+func (f *flakyPublisher) Close() error {
+	return nil
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "nil error", err: nil, expected: false},
+		{name: "unavailable", err: status.Error(codes.Unavailable, "down"), expected: true},
+		{name: "deadline exceeded", err: status.Error(codes.DeadlineExceeded, "slow"), expected: true},
+		{name: "context deadline", err: context.DeadlineExceeded, expected: true},
+		{name: "publishing paused", err: pubsub.ErrPublishingPaused{OrderingKey: "user-1"}, expected: true},
+		{name: "invalid argument", err: status.Error(codes.InvalidArgument, "bad"), expected: false},
+		{name: "not found", err: status.Error(codes.NotFound, "no topic"), expected: false},
+		{name: "plain error", err: errors.New("boom"), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.expected {
+				t.Errorf("IsRetryable() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	expected := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	for i, want := range expected {
+		if got := policy.backoff(i + 1); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, want)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		got := policy.backoff(2)
+		if got < 100*time.Millisecond || got > 200*time.Millisecond {
+			t.Fatalf("backoff with jitter = %v, want between 100ms and 200ms", got)
+		}
+	}
+}
+
+func TestRetryPublisher(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "down")
+	invalid := status.Error(codes.InvalidArgument, "bad")
+
+	tests := []struct {
+		name             string
+		errs             []error
+		maxAttempts      int
+		expectedErr      error
+		expectedAttempts int
+	}{
+		{
+			name:             "succeeds without retry",
+			maxAttempts:      3,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "retries transient errors",
+			errs:             []error{unavailable, unavailable},
+			maxAttempts:      3,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "gives up after max attempts",
+			errs:             []error{unavailable, unavailable, unavailable},
+			maxAttempts:      2,
+			expectedErr:      unavailable,
+			expectedAttempts: 2,
+		},
+		{
+			name:             "does not retry permanent errors",
+			errs:             []error{invalid},
+			maxAttempts:      3,
+			expectedErr:      invalid,
+			expectedAttempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flaky := &flakyPublisher{errs: tt.errs}
+			p := NewRetryPublisher(flaky, RetryPolicy{
+				MaxAttempts: tt.maxAttempts,
+				BaseDelay:   time.Millisecond,
+			})
+
+			err := p.PublishAPILogEvent(context.Background(), newTestEvent("req-1"))
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+			if flaky.attempts != tt.expectedAttempts {
+				t.Errorf("Expected %d attempts, got %d", tt.expectedAttempts, flaky.attempts)
+			}
+		})
+	}
+}
+
+func TestRetryPublisher_StopsOnContextDone(t *testing.T) {
+	flaky := &flakyPublisher{errs: []error{status.Error(codes.Unavailable, "down")}}
+	p := NewRetryPublisher(flaky, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := p.PublishAPILogEvent(ctx, newTestEvent("req-1")); err == nil {
+		t.Error("Expected error when context is done during backoff")
+	}
+	if flaky.attempts != 1 {
+		t.Errorf("Expected 1 attempt, got %d", flaky.attempts)
+	}
+}
+
+func TestRetryPublisher_WithFakeServer(t *testing.T) {
+	client, srv := newTestClient(t, Options{})
+	srv.SetAutoPublishResponse(false)
+
+	// The client retries Unavailable internally, so inject an error it gives up on
+	// and classify it as transient in the policy to exercise our own retry loop
+	srv.AddPublishResponse(nil, status.Error(codes.FailedPrecondition, "injected failure"))
+	srv.AddPublishResponse(&pb.PublishResponse{MessageIds: []string{"m1"}}, nil)
+
+	p := NewRetryPublisher(client, RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		Retryable: func(err error) bool {
+			return status.Code(err) == codes.FailedPrecondition
+		},
+	})
+
+	if err := p.PublishAPILogEvent(context.Background(), newTestEvent("req-1")); err != nil {
+		t.Fatalf("PublishAPILogEvent() error = %v", err)
+	}
+}
+
+func TestRetryPublisher_WithFakeServerPermanentError(t *testing.T) {
+	client, srv := newTestClient(t, Options{})
+	srv.SetAutoPublishResponse(false)
+	srv.AddPublishResponse(nil, status.Error(codes.FailedPrecondition, "injected failure"))
+
+	p := NewRetryPublisher(client, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+
+	err := p.PublishAPILogEvent(context.Background(), newTestEvent("req-1"))
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition error, got %v", err)
+	}
+}
@@ -0,0 +1,268 @@
+package pubsub
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"api-pubsub-logger/pkg/logger"
+)
+
+// spoolPrefix is the file name prefix of spool segments
+const spoolPrefix = "spool"
+
+// deadLetterFile is the file spooled events failing to replay with a permanent error are moved to
+const deadLetterFile = "dead-letter" + segmentExt
+
+// ErrSpoolFull is returned when appending to a spool that reached its size cap
+var ErrSpoolFull = errors.New("spool is full")
+
+// Spool is a durable on-disk queue of API log events stored as NDJSON segments
+type Spool struct {
+	writer   *rotatingWriter
+	maxBytes int64
+	size     atomic.Int64
+	// deadLettered counts the events moved to the dead-letter file
+	deadLettered atomic.Int64
+
+	// replayMu makes sure only one replay runs at a time
+	replayMu sync.Mutex
+}
+
+// OpenSpool opens (or creates) a spool in dir
+// Segments are rotated at maxSegmentBytes and appends fail once the spool holds maxBytes
+// Appends are synced to disk before they return, so accepted events survive a crash
+func OpenSpool(dir string, maxSegmentBytes, maxBytes int64) (*Spool, error) {
+	writer, err := newRotatingWriter(dir, spoolPrefix, maxSegmentBytes)
+	if err != nil {
+		return nil, err
+	}
+	writer.sync = true
+
+	s := &Spool{
+		writer:   writer,
+		maxBytes: maxBytes,
+	}
+	if err := s.refreshSize(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Append writes an API log event to the spool
+func (s *Spool) Append(event logger.APILogEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if s.maxBytes > 0 && s.size.Load()+int64(len(data))+1 > s.maxBytes {
+		return ErrSpoolFull
+	}
+
+	if err := s.writer.writeLine(data); err != nil {
+		return err
+	}
+	s.size.Add(int64(len(data)) + 1)
+	return nil
+}
+
+// Size returns the number of bytes currently stored in the spool
+func (s *Spool) Size() int64 {
+	return s.size.Load()
+}
+
+// DeadLettered returns the number of events moved to the dead-letter file since the spool was opened
+func (s *Spool) DeadLettered() int64 {
+	return s.deadLettered.Load()
+}
+
+// Replay re-publishes spooled events oldest first and removes them once published
+// It stops at the first retryable publish error, keeping the remaining events for the next replay,
+// events failing with a permanent error are moved to the dead-letter file so they do not block the others
+func (s *Spool) Replay(ctx context.Context, publish func(context.Context, logger.APILogEvent) error) (int, error) {
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+	defer s.refreshSize()
+
+	// Seal the active segment so new appends do not race with the replay
+	if err := s.writer.rotate(); err != nil {
+		return 0, err
+	}
+
+	segments, err := s.writer.segments()
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for _, path := range segments {
+		n, dead, err := s.replaySegment(ctx, path, publish)
+		replayed += n
+		s.deadLettered.Add(int64(dead))
+		if err != nil {
+			return replayed, err
+		}
+	}
+	return replayed, nil
+}
+
+// Close closes the active spool segment
+func (s *Spool) Close() error {
+	return s.writer.close()
+}
+
+// refreshSize recomputes the spool size from the files on disk
+func (s *Spool) refreshSize() error {
+	entries, err := os.ReadDir(s.writer.dir)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, spoolPrefix+"-") || filepath.Ext(name) != segmentExt {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		total += info.Size()
+	}
+	s.size.Store(total)
+	return nil
+}
+
+// replaySegment publishes every event of a segment, then deletes it
+// On a retryable failure the segment is rewritten with the events that were not published yet,
+// and events failing with a permanent error are appended to the dead-letter file
+// It returns the number of published and dead-lettered events
+func (s *Spool) replaySegment(ctx context.Context, path string, publish func(context.Context, logger.APILogEvent) error) (int, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var lines [][]byte
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		if line := scanner.Bytes(); len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+
+	published := 0
+	var dead [][]byte
+	for i, line := range lines {
+		var event logger.APILogEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			log.Printf("Skipping corrupt spooled API log event in %s: %v", path, err)
+			continue
+		}
+
+		err := publish(ctx, event)
+		if err == nil {
+			published++
+			continue
+		}
+		if ctx.Err() == nil && !isReplayRetryable(err) {
+			log.Printf("Moving spooled API log event %s to the dead-letter file after a permanent failure: %v", event.RequestID.String, err)
+			dead = append(dead, line)
+			continue
+		}
+
+		// Dead letters are written first so a failed rewrite replays them rather than losing them
+		if deadErr := s.appendDeadLetters(dead); deadErr != nil {
+			return published, 0, errors.Join(err, deadErr)
+		}
+		if rewriteErr := rewriteSegment(path, lines[i:]); rewriteErr != nil {
+			return published, len(dead), errors.Join(err, rewriteErr)
+		}
+		return published, len(dead), err
+	}
+
+	if err := s.appendDeadLetters(dead); err != nil {
+		return published, 0, err
+	}
+	return published, len(dead), os.Remove(path)
+}
+
+// appendDeadLetters appends lines to the dead-letter file and syncs it
+func (s *Spool) appendDeadLetters(lines [][]byte) error {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	file, err := os.OpenFile(filepath.Join(s.writer.dir, deadLetterFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := writeLinesSynced(file, lines); err != nil {
+		return err
+	}
+	return syncDir(s.writer.dir)
+}
+
+// isReplayRetryable reports whether a spooled event that failed to replay should stay in the spool
+// Besides IsRetryable errors, a closed or tripped publisher and local I/O errors are transient
+func isReplayRetryable(err error) bool {
+	var pathErr *fs.PathError
+	return IsRetryable(err) ||
+		errors.Is(err, ErrBreakerOpen) ||
+		errors.Is(err, ErrClientClosed) ||
+		errors.Is(err, ErrPublisherClosed) ||
+		errors.Is(err, context.Canceled) ||
+		errors.As(err, &pathErr)
+}
+
+// rewriteSegment atomically replaces a segment with the given lines, syncing it before the rename
+func rewriteSegment(path string, lines [][]byte) error {
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := writeLinesSynced(file, lines); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// writeLinesSynced writes NDJSON lines to file, syncs and closes it
+func writeLinesSynced(file *os.File, lines [][]byte) error {
+	if _, err := file.Write(append(bytes.Join(lines, []byte("\n")), '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// syncDir syncs a directory so that the files created or renamed in it survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,168 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"api-pubsub-logger/pkg/logger"
+)
+
+// SpoolPublisher is a Publisher decorator writing events that failed to publish to a Spool
+// and re-publishing them in the background once the underlying publisher recovers
+type SpoolPublisher struct {
+	next           Publisher
+	spool          *Spool
+	replayInterval time.Duration
+	orderingKey    func(logger.APILogEvent) string
+
+	// mu guards spooledKeys, the ordering keys with events waiting in the spool
+	mu          sync.Mutex
+	spooledKeys map[string]struct{}
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewSpoolPublisher wraps next with spool as a dead-letter fallback
+// Spooled events are replayed every replayInterval, replay is disabled when it is zero
+// With an ordering key, the events following a spooled event with the same key are spooled
+// too until the spool is replayed, so that they are not published before it
+func NewSpoolPublisher(next Publisher, spool *Spool, replayInterval time.Duration, orderingKey func(logger.APILogEvent) string) *SpoolPublisher {
+	p := &SpoolPublisher{
+		next:           next,
+		spool:          spool,
+		replayInterval: replayInterval,
+		orderingKey:    orderingKey,
+		spooledKeys:    make(map[string]struct{}),
+		stop:           make(chan struct{}),
+	}
+
+	if replayInterval > 0 {
+		p.wg.Add(1)
+		go p.replayLoop()
+	}
+
+	return p
+}
+
+// PublishAPILogEvent publishes an API log event, spooling it to disk if publishing fails
+func (p *SpoolPublisher) PublishAPILogEvent(ctx context.Context, event logger.APILogEvent) error {
+	var key string
+	if p.orderingKey != nil {
+		key = p.orderingKey(event)
+	}
+
+	if key != "" {
+		p.mu.Lock()
+		if _, spooled := p.spooledKeys[key]; spooled {
+			defer p.mu.Unlock()
+			return p.spool.Append(event)
+		}
+		p.mu.Unlock()
+	}
+
+	err := p.next.PublishAPILogEvent(ctx, event)
+	if err == nil {
+		return nil
+	}
+
+	if spoolErr := p.appendSpool(event, key); spoolErr != nil {
+		return errors.Join(err, spoolErr)
+	}
+
+	log.Printf("Spooled API log event after publish failure: %v", err)
+	return nil
+}
+
+// SpoolFailed spools an event that failed to publish in the background (e.g. an async Pub/Sub
+// result or a webhook batch), recording its key so the following events with it are spooled too
+func (p *SpoolPublisher) SpoolFailed(event logger.APILogEvent, err error) {
+	var key string
+	if p.orderingKey != nil {
+		key = p.orderingKey(event)
+	}
+
+	if spoolErr := p.appendSpool(event, key); spoolErr != nil {
+		log.Printf("Failed to spool API log event: %v", errors.Join(err, spoolErr))
+	}
+}
+
+// appendSpool appends an event to the spool and records its ordering key
+func (p *SpoolPublisher) appendSpool(event logger.APILogEvent, key string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.spool.Append(event); err != nil {
+		return err
+	}
+	if key != "" {
+		p.spooledKeys[key] = struct{}{}
+	}
+	return nil
+}
+
+// Replay re-publishes spooled events through the underlying publisher
+func (p *SpoolPublisher) Replay(ctx context.Context) (int, error) {
+	n, err := p.spool.Replay(ctx, p.next.PublishAPILogEvent)
+	if err != nil {
+		return n, err
+	}
+
+	// Events appended during the replay wait for the next one, and so do their keys
+	p.mu.Lock()
+	if p.spool.Size() == 0 {
+		clear(p.spooledKeys)
+	}
+	p.mu.Unlock()
+	return n, nil
+}
+
+// Close stops the replayer, closes the underlying publisher and then the spool
+func (p *SpoolPublisher) Close() error {
+	close(p.stop)
+	p.wg.Wait()
+
+	// The underlying publisher may still spool events while closing (e.g. async results)
+	return errors.Join(p.next.Close(), p.spool.Close())
+}
+
+// replayLoop periodically replays spooled events until Close is called
+func (p *SpoolPublisher) replayLoop() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.replayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+
+		if p.spool.Size() == 0 {
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-p.stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		n, err := p.Replay(ctx)
+		cancel()
+		if n > 0 {
+			log.Printf("Replayed %d spooled API log events", n)
+		}
+		if err != nil {
+			log.Printf("Failed to replay spooled API log events: %v", err)
+		}
+	}
+}
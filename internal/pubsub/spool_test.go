@@ -0,0 +1,346 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"api-pubsub-logger/pkg/logger"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestSpool_AppendAndReplay(t *testing.T) {
+	spool, err := OpenSpool(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatalf("OpenSpool() error = %v", err)
+	}
+	defer spool.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := spool.Append(newTestEvent(fmt.Sprintf("req-%d", i))); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	var replayed []logger.APILogEvent
+	n, err := spool.Replay(context.Background(), func(_ context.Context, event logger.APILogEvent) error {
+		replayed = append(replayed, event)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+
+	if n != 3 || len(replayed) != 3 {
+		t.Fatalf("Expected 3 replayed events, got %d", len(replayed))
+	}
+	for i, event := range replayed {
+		if want := fmt.Sprintf("req-%d", i); event.RequestID.String != want {
+			t.Errorf("Expected replayed event %d = %v, got %v", i, want, event.RequestID.String)
+		}
+	}
+
+	if spool.Size() != 0 {
+		t.Errorf("Expected empty spool after replay, got %d bytes", spool.Size())
+	}
+}
+
+func TestSpool_ReplayKeepsUnpublishedEvents(t *testing.T) {
+	spool, err := OpenSpool(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatalf("OpenSpool() error = %v", err)
+	}
+	defer spool.Close()
+
+	for i := 0; i < 3; i++ {
+		spool.Append(newTestEvent(fmt.Sprintf("req-%d", i)))
+	}
+
+	// Fail on the second event
+	calls := 0
+	publishErr := status.Error(codes.Unavailable, "still down")
+	n, err := spool.Replay(context.Background(), func(_ context.Context, event logger.APILogEvent) error {
+		calls++
+		if calls == 2 {
+			return publishErr
+		}
+		return nil
+	})
+	if !errors.Is(err, publishErr) {
+		t.Fatalf("Expected publish error, got %v", err)
+	}
+	if n != 1 {
+		t.Errorf("Expected 1 replayed event, got %d", n)
+	}
+
+	// The next replay resumes at the failed event
+	var replayed []string
+	if _, err := spool.Replay(context.Background(), func(_ context.Context, event logger.APILogEvent) error {
+		replayed = append(replayed, event.RequestID.String)
+		return nil
+	}); err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+
+	if len(replayed) != 2 || replayed[0] != "req-1" || replayed[1] != "req-2" {
+		t.Errorf("Expected [req-1 req-2] to be replayed, got %v", replayed)
+	}
+}
+
+func TestSpool_ReplayDeadLettersPermanentFailures(t *testing.T) {
+	dir := t.TempDir()
+	spool, err := OpenSpool(dir, 0, 0)
+	if err != nil {
+		t.Fatalf("OpenSpool() error = %v", err)
+	}
+	defer spool.Close()
+
+	for i := 0; i < 3; i++ {
+		spool.Append(newTestEvent(fmt.Sprintf("req-%d", i)))
+	}
+
+	// req-1 can never be published, it must not block req-2
+	var replayed []string
+	n, err := spool.Replay(context.Background(), func(_ context.Context, event logger.APILogEvent) error {
+		if event.RequestID.String == "req-1" {
+			return fmt.Errorf("%w: 20000000 bytes", ErrMessageTooLarge)
+		}
+		replayed = append(replayed, event.RequestID.String)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if n != 2 || !reflect.DeepEqual(replayed, []string{"req-0", "req-2"}) {
+		t.Errorf("Expected [req-0 req-2] to be replayed, got %d %v", n, replayed)
+	}
+	if spool.Size() != 0 || spool.DeadLettered() != 1 {
+		t.Errorf("Expected empty spool and 1 dead letter, got %d bytes and %d", spool.Size(), spool.DeadLettered())
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, deadLetterFile))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if !strings.Contains(string(data), `"req-1"`) || strings.Count(string(data), "\n") != 1 {
+		t.Errorf("Expected req-1 in the dead-letter file, got %s", data)
+	}
+
+	// Dead letters are not replayed again
+	calls := 0
+	spool.Replay(context.Background(), func(context.Context, logger.APILogEvent) error {
+		calls++
+		return nil
+	})
+	if calls != 0 {
+		t.Errorf("Expected no replay of dead letters, got %d calls", calls)
+	}
+}
+
+func TestSpool_ReplayKeepsEventsWhenCanceled(t *testing.T) {
+	spool, err := OpenSpool(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatalf("OpenSpool() error = %v", err)
+	}
+	defer spool.Close()
+
+	spool.Append(newTestEvent("req-1"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	spool.Replay(ctx, func(ctx context.Context, _ logger.APILogEvent) error {
+		return errors.New("publish aborted")
+	})
+	if spool.Size() == 0 || spool.DeadLettered() != 0 {
+		t.Errorf("Expected the event to stay in the spool, got %d bytes and %d dead letters", spool.Size(), spool.DeadLettered())
+	}
+}
+
+func TestSpool_RotatesSegments(t *testing.T) {
+	dir := t.TempDir()
+	spool, err := OpenSpool(dir, 100, 0)
+	if err != nil {
+		t.Fatalf("OpenSpool() error = %v", err)
+	}
+	defer spool.Close()
+
+	for i := 0; i < 3; i++ {
+		spool.Append(newTestEvent(fmt.Sprintf("req-%d", i)))
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("Expected 3 segments, got %d", len(entries))
+	}
+}
+
+func TestSpool_EnforcesSizeCap(t *testing.T) {
+	spool, err := OpenSpool(t.TempDir(), 0, 300)
+	if err != nil {
+		t.Fatalf("OpenSpool() error = %v", err)
+	}
+	defer spool.Close()
+
+	var appendErr error
+	for i := 0; i < 10 && appendErr == nil; i++ {
+		appendErr = spool.Append(newTestEvent("req"))
+	}
+
+	if !errors.Is(appendErr, ErrSpoolFull) {
+		t.Errorf("Expected ErrSpoolFull, got %v", appendErr)
+	}
+	if spool.Size() > 300 {
+		t.Errorf("Expected spool size <= 300, got %d", spool.Size())
+	}
+}
+
+func TestSpool_ReopensExistingSegments(t *testing.T) {
+	dir := t.TempDir()
+	spool, _ := OpenSpool(dir, 0, 0)
+	spool.Append(newTestEvent("req-1"))
+	spool.Close()
+
+	reopened, err := OpenSpool(dir, 0, 0)
+	if err != nil {
+		t.Fatalf("OpenSpool() error = %v", err)
+	}
+	defer reopened.Close()
+
+	if reopened.Size() == 0 {
+		t.Fatal("Expected reopened spool to account for existing segments")
+	}
+
+	n, err := reopened.Replay(context.Background(), func(context.Context, logger.APILogEvent) error { return nil })
+	if err != nil || n != 1 {
+		t.Errorf("Expected 1 replayed event, got %d (err = %v)", n, err)
+	}
+}
+
+func TestSpoolPublisher(t *testing.T) {
+	spool, err := OpenSpool(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatalf("OpenSpool() error = %v", err)
+	}
+
+	mock := &mockPublisher{publishError: errors.New("pubsub down")}
+	p := NewSpoolPublisher(mock, spool, 0, nil)
+	defer p.Close()
+
+	// A failed publish is spooled instead of being reported as lost
+	if err := p.PublishAPILogEvent(context.Background(), newTestEvent("req-1")); err != nil {
+		t.Fatalf("PublishAPILogEvent() error = %v", err)
+	}
+	if spool.Size() == 0 {
+		t.Fatal("Expected failed event to be spooled")
+	}
+
+	// Once the publisher recovers the spooled event is replayed
+	mock.mu.Lock()
+	mock.publishError = nil
+	mock.mu.Unlock()
+
+	n, err := p.Replay(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("Expected 1 replayed event, got %d (err = %v)", n, err)
+	}
+	if spool.Size() != 0 {
+		t.Errorf("Expected empty spool after replay, got %d bytes", spool.Size())
+	}
+
+	events := mock.getEvents()
+	if len(events) != 2 || events[1].RequestID.String != "req-1" {
+		t.Errorf("Expected spooled event to be re-published, got %v", events)
+	}
+}
+
+func TestSpoolPublisher_KeepsKeyOrder(t *testing.T) {
+	spool, err := OpenSpool(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatalf("OpenSpool() error = %v", err)
+	}
+
+	mock := &mockPublisher{publishError: errors.New("pubsub down")}
+	p := NewSpoolPublisher(mock, spool, 0, func(event logger.APILogEvent) string { return event.UserID.String })
+	defer p.Close()
+
+	newUserEvent := func(requestID, userID string) logger.APILogEvent {
+		event := newTestEvent(requestID)
+		event.UserID = null.StringFrom(userID)
+		return event
+	}
+
+	p.PublishAPILogEvent(context.Background(), newUserEvent("req-1", "user-1"))
+
+	mock.mu.Lock()
+	mock.publishError = nil
+	mock.mu.Unlock()
+
+	// user-1 waits for its spooled event while other keys are published right away
+	p.PublishAPILogEvent(context.Background(), newUserEvent("req-2", "user-1"))
+	p.PublishAPILogEvent(context.Background(), newUserEvent("req-3", "user-2"))
+
+	if n, err := p.Replay(context.Background()); err != nil || n != 2 {
+		t.Fatalf("Expected 2 replayed events, got %d (err = %v)", n, err)
+	}
+
+	// Once the spool is drained, user-1 is published right away again
+	p.PublishAPILogEvent(context.Background(), newUserEvent("req-4", "user-1"))
+
+	var got []string
+	for _, event := range mock.getEvents() {
+		got = append(got, event.RequestID.String)
+	}
+	want := []string{"req-1", "req-3", "req-1", "req-2", "req-4"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected publishes %v, got %v", want, got)
+	}
+}
+
+func TestSpoolPublisher_SpoolFailedKeepsKeyOrder(t *testing.T) {
+	spool, err := OpenSpool(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatalf("OpenSpool() error = %v", err)
+	}
+
+	mock := &mockPublisher{}
+	p := NewSpoolPublisher(mock, spool, 0, func(event logger.APILogEvent) string { return event.UserID.String })
+	defer p.Close()
+
+	newUserEvent := func(requestID, userID string) logger.APILogEvent {
+		event := newTestEvent(requestID)
+		event.UserID = null.StringFrom(userID)
+		return event
+	}
+
+	// req-1 was accepted, then failed in the background (e.g. an async Pub/Sub result)
+	p.PublishAPILogEvent(context.Background(), newUserEvent("req-1", "user-1"))
+	p.SpoolFailed(newUserEvent("req-1", "user-1"), errors.New("pubsub down"))
+
+	// user-1 now waits for its spooled event
+	p.PublishAPILogEvent(context.Background(), newUserEvent("req-2", "user-1"))
+	p.PublishAPILogEvent(context.Background(), newUserEvent("req-3", "user-2"))
+
+	if n, err := p.Replay(context.Background()); err != nil || n != 2 {
+		t.Fatalf("Expected 2 replayed events, got %d (err = %v)", n, err)
+	}
+
+	var got []string
+	for _, event := range mock.getEvents() {
+		got = append(got, event.RequestID.String)
+	}
+	want := []string{"req-1", "req-3", "req-1", "req-2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected publishes %v, got %v", want, got)
+	}
+}
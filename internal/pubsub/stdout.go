@@ -0,0 +1,80 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"api-pubsub-logger/pkg/logger"
+)
+
+// StdoutPublisher writes API log events to a writer (usually os.Stdout)
+type StdoutPublisher struct {
+	mu     sync.Mutex
+	w      io.Writer
+	pretty bool
+}
+
+// NewStdoutPublisher creates a publisher writing to w in the given format:
+// json writes one JSON object per line, pretty writes a human readable summary line
+func NewStdoutPublisher(w io.Writer, format string) (*StdoutPublisher, error) {
+	switch strings.ToLower(format) {
+	case "", "json":
+		return &StdoutPublisher{w: w}, nil
+	case "pretty":
+		return &StdoutPublisher{w: w, pretty: true}, nil
+	default:
+		return nil, fmt.Errorf("unknown stdout format %q", format)
+	}
+}
+
+// PublishAPILogEvent writes an API log event
+func (p *StdoutPublisher) PublishAPILogEvent(_ context.Context, event logger.APILogEvent) error {
+	var line []byte
+	if p.pretty {
+		line = []byte(formatPretty(event))
+	} else {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		line = data
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := p.w.Write(append(line, '\n'))
+	return err
+}
+
+// Close is a no-op, the writer is owned by the caller
+func (p *StdoutPublisher) Close() error {
+	return nil
+}
+
+// formatPretty renders an API log event as a single human readable line
+func formatPretty(event logger.APILogEvent) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s %d %s",
+		event.CreatedAt.Format(time.RFC3339),
+		event.Method,
+		event.URL,
+		event.ResponseCode,
+		time.Duration(event.Duration*float64(time.Second)).Round(time.Microsecond),
+	)
+	fmt.Fprintf(&b, " service=%s", event.Service)
+	if event.Name != "" {
+		fmt.Fprintf(&b, " route=%s", event.Name)
+	}
+	if event.RequestID.Valid {
+		fmt.Fprintf(&b, " request_id=%s", event.RequestID.String)
+	}
+	if event.UserID.Valid {
+		fmt.Fprintf(&b, " user_id=%s", event.UserID.String)
+	}
+	return b.String()
+}
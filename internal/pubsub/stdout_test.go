@@ -0,0 +1,70 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"api-pubsub-logger/pkg/logger"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestStdoutPublisher_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	p, err := NewStdoutPublisher(&buf, "json")
+	if err != nil {
+		t.Fatalf("NewStdoutPublisher() error = %v", err)
+	}
+
+	p.PublishAPILogEvent(context.Background(), newTestEvent("req-1"))
+	p.PublishAPILogEvent(context.Background(), newTestEvent("req-2"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+
+	var event logger.APILogEvent
+	if err := json.Unmarshal([]byte(lines[1]), &event); err != nil {
+		t.Fatalf("Failed to decode line: %v", err)
+	}
+	if event.RequestID.String != "req-2" {
+		t.Errorf("Expected request ID = req-2, got %v", event.RequestID)
+	}
+}
+
+func TestStdoutPublisher_Pretty(t *testing.T) {
+	var buf bytes.Buffer
+	p, err := NewStdoutPublisher(&buf, "pretty")
+	if err != nil {
+		t.Fatalf("NewStdoutPublisher() error = %v", err)
+	}
+
+	event := logger.APILogEvent{
+		RequestID:    null.StringFrom("req-1"),
+		Service:      "test-service",
+		Method:       "POST",
+		URL:          "/v1/items",
+		ResponseCode: 201,
+		UserID:       null.StringFrom("user-1"),
+		Name:         "create_item",
+		Duration:     0.0125,
+		CreatedAt:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	p.PublishAPILogEvent(context.Background(), event)
+
+	expected := "2024-01-02T03:04:05Z POST /v1/items 201 12.5ms service=test-service route=create_item request_id=req-1 user_id=user-1\n"
+	if buf.String() != expected {
+		t.Errorf("Output = %q, want %q", buf.String(), expected)
+	}
+}
+
+func TestNewStdoutPublisher_UnknownFormat(t *testing.T) {
+	if _, err := NewStdoutPublisher(&bytes.Buffer{}, "xml"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
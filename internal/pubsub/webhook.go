@@ -0,0 +1,251 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"api-pubsub-logger/pkg/logger"
+)
+
+// ErrPublisherClosed is returned when publishing to a publisher that was closed
+var ErrPublisherClosed = errors.New("publisher is closed")
+
+// webhookQueuedBatches is the number of batches that can wait for each poster,
+// further batches fail with ErrQueueFull rather than blocking the publishers
+const webhookQueuedBatches = 4
+
+// WebhookOptions contains configuration options for the WebhookPublisher
+type WebhookOptions struct {
+	URL           string
+	Headers       map[string]string
+	BatchSize     int
+	FlushInterval time.Duration
+	Timeout       time.Duration
+	// MaxInFlight caps the batches being posted at once, 4 when zero
+	MaxInFlight int
+	// OrderingKey batches the events with the same key together, and posts their batches one at a time
+	OrderingKey func(logger.APILogEvent) string
+	// HTTPClient overrides the client used to post batches
+	HTTPClient *http.Client
+	// OnError is called for each event of a batch that failed to post
+	OnError func(logger.APILogEvent, error)
+}
+
+// WebhookPublisher posts API log events in batches (JSON arrays) to an HTTP endpoint
+// Batches are posted in the background by MaxInFlight posters, so publishing does not wait for the endpoint
+type WebhookPublisher struct {
+	opts   WebhookOptions
+	client *http.Client
+
+	// ctx is canceled when Shutdown gives up on the posts in flight
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// mu guards the current batch of each poster, their queues and closed
+	mu      sync.Mutex
+	batches []*webhookBatch
+	queues  []chan []logger.APILogEvent
+	unkeyed int
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// webhookBatch is a set of events posted together by a poster
+type webhookBatch struct {
+	poster int
+	events []logger.APILogEvent
+	timer  *time.Timer
+}
+
+// NewWebhookPublisher creates a publisher posting batches to opts.URL
+func NewWebhookPublisher(opts WebhookOptions) (*WebhookPublisher, error) {
+	if opts.URL == "" {
+		return nil, errors.New("webhook URL is empty")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.MaxInFlight <= 0 {
+		opts.MaxInFlight = 4
+	}
+
+	client := opts.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: opts.Timeout}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &WebhookPublisher{
+		opts:    opts,
+		client:  client,
+		ctx:     ctx,
+		cancel:  cancel,
+		batches: make([]*webhookBatch, opts.MaxInFlight),
+		queues:  make([]chan []logger.APILogEvent, opts.MaxInFlight),
+	}
+
+	for i := range p.queues {
+		p.queues[i] = make(chan []logger.APILogEvent, webhookQueuedBatches)
+		p.wg.Add(1)
+		go p.poster(p.queues[i])
+	}
+
+	return p, nil
+}
+
+// PublishAPILogEvent adds an API log event to the current batch without waiting for it to be posted,
+// failures to post are passed to OnError
+func (p *WebhookPublisher) PublishAPILogEvent(_ context.Context, event logger.APILogEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrPublisherClosed
+	}
+
+	i := p.posterLocked(event)
+	if p.batches[i] == nil {
+		b := &webhookBatch{poster: i}
+		b.timer = time.AfterFunc(p.opts.FlushInterval, func() { p.flushBatch(b) })
+		p.batches[i] = b
+	}
+	p.batches[i].events = append(p.batches[i].events, event)
+	if len(p.batches[i].events) >= p.opts.BatchSize {
+		p.sendLocked(i)
+	}
+	return nil
+}
+
+// Shutdown posts the pending batches and waits for the posters to finish or ctx to be done
+// When ctx is done first, the posts in flight are canceled and their events passed to OnError
+func (p *WebhookPublisher) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		for i, b := range p.batches {
+			if b != nil {
+				p.sendLocked(i)
+			}
+		}
+		for _, queue := range p.queues {
+			close(queue)
+		}
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Close posts the pending batches and waits for the posts in flight to complete
+func (p *WebhookPublisher) Close() error {
+	defer p.cancel()
+	return p.Shutdown(context.Background())
+}
+
+// posterLocked returns the poster of an event, p.mu must be held
+// Events with an ordering key always go to the same poster, the others fill one batch at a time
+func (p *WebhookPublisher) posterLocked(event logger.APILogEvent) int {
+	if p.opts.OrderingKey != nil {
+		if key := p.opts.OrderingKey(event); key != "" {
+			return keyPartition(key, len(p.queues))
+		}
+	}
+	return p.unkeyed
+}
+
+// flushBatch posts b when its flush interval elapses, unless it was already sent
+func (p *WebhookPublisher) flushBatch(b *webhookBatch) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.batches[b.poster] == b {
+		p.sendLocked(b.poster)
+	}
+}
+
+// sendLocked detaches the current batch of a poster and queues it for posting, p.mu must be held
+func (p *WebhookPublisher) sendLocked(i int) {
+	b := p.batches[i]
+	p.batches[i] = nil
+	b.timer.Stop()
+	if i == p.unkeyed {
+		p.unkeyed = (p.unkeyed + 1) % len(p.queues)
+	}
+
+	select {
+	case p.queues[i] <- b.events:
+	default:
+		p.failed(b.events, ErrQueueFull)
+	}
+}
+
+// poster posts the batches of a queue one at a time, in order
+func (p *WebhookPublisher) poster(queue chan []logger.APILogEvent) {
+	defer p.wg.Done()
+	for events := range queue {
+		if err := p.post(events); err != nil {
+			p.failed(events, err)
+		}
+	}
+}
+
+// failed logs a batch that could not be posted and passes its events to OnError
+func (p *WebhookPublisher) failed(events []logger.APILogEvent, err error) {
+	log.Printf("Failed to post %d API log events to webhook: %v", len(events), err)
+	if p.opts.OnError != nil {
+		for _, event := range events {
+			p.opts.OnError(event, err)
+		}
+	}
+}
+
+// post sends events as a JSON array to the webhook
+func (p *WebhookPublisher) post(events []logger.APILogEvent) error {
+	data, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(p.ctx, http.MethodPost, p.opts.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range p.opts.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
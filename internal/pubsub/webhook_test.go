@@ -0,0 +1,351 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"api-pubsub-logger/pkg/logger"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+// webhookRecorder is an httptest handler recording the batches it receives
+type webhookRecorder struct {
+	mu      sync.Mutex
+	batches [][]logger.APILogEvent
+	headers []http.Header
+	status  int
+}
+
+func (rec *webhookRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var batch []logger.APILogEvent
+	json.NewDecoder(r.Body).Decode(&batch)
+
+	rec.mu.Lock()
+	rec.batches = append(rec.batches, batch)
+	rec.headers = append(rec.headers, r.Header.Clone())
+	status := rec.status
+	rec.mu.Unlock()
+
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+}
+
+func (rec *webhookRecorder) getBatches() [][]logger.APILogEvent {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([][]logger.APILogEvent(nil), rec.batches...)
+}
+
+func TestWebhookPublisher_PostsFullBatch(t *testing.T) {
+	rec := &webhookRecorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	p, err := NewWebhookPublisher(WebhookOptions{
+		URL:           srv.URL,
+		Headers:       map[string]string{"Authorization": "Bearer secret"},
+		BatchSize:     3,
+		FlushInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("NewWebhookPublisher() error = %v", err)
+	}
+	defer p.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := p.PublishAPILogEvent(context.Background(), newTestEvent("req")); err != nil {
+			t.Fatalf("PublishAPILogEvent() error = %v", err)
+		}
+	}
+	waitFor(t, func() bool { return len(rec.getBatches()) > 0 })
+
+	batches := rec.getBatches()
+	if len(batches) != 1 || len(batches[0]) != 3 {
+		t.Fatalf("Expected a single batch of 3 events, got %v", batches)
+	}
+
+	rec.mu.Lock()
+	headers := rec.headers[0]
+	rec.mu.Unlock()
+	if headers.Get("Content-Type") != "application/json" || headers.Get("Authorization") != "Bearer secret" {
+		t.Errorf("Unexpected headers: %v", headers)
+	}
+}
+
+func TestWebhookPublisher_FlushesOnInterval(t *testing.T) {
+	rec := &webhookRecorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	p, _ := NewWebhookPublisher(WebhookOptions{
+		URL:           srv.URL,
+		BatchSize:     100,
+		FlushInterval: 10 * time.Millisecond,
+	})
+	defer p.Close()
+
+	if err := p.PublishAPILogEvent(context.Background(), newTestEvent("req-1")); err != nil {
+		t.Fatalf("PublishAPILogEvent() error = %v", err)
+	}
+	waitFor(t, func() bool { return len(rec.getBatches()) > 0 })
+
+	batches := rec.getBatches()
+	if len(batches) != 1 || batches[0][0].RequestID.String != "req-1" {
+		t.Errorf("Expected partial batch to be flushed, got %v", batches)
+	}
+}
+
+func TestWebhookPublisher_ReportsErrorStatus(t *testing.T) {
+	rec := &webhookRecorder{status: http.StatusServiceUnavailable}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var failed []string
+	p, _ := NewWebhookPublisher(WebhookOptions{
+		URL:       srv.URL,
+		BatchSize: 1,
+		OnError: func(event logger.APILogEvent, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failed = append(failed, event.RequestID.String)
+		},
+	})
+
+	if err := p.PublishAPILogEvent(context.Background(), newTestEvent("req-1")); err != nil {
+		t.Fatalf("PublishAPILogEvent() error = %v", err)
+	}
+	p.Close()
+
+	if len(failed) != 1 || failed[0] != "req-1" {
+		t.Errorf("Expected req-1 to be reported for the 503 response, got %v", failed)
+	}
+}
+
+func TestWebhookPublisher_BatchesThroughDispatcher(t *testing.T) {
+	rec := &webhookRecorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	p, _ := NewWebhookPublisher(WebhookOptions{URL: srv.URL, BatchSize: 20, FlushInterval: time.Hour})
+	d := NewDispatcher(p, DispatcherOptions{})
+	defer d.Close()
+
+	for i := 0; i < 20; i++ {
+		if err := d.PublishAPILogEvent(context.Background(), newTestEvent("req")); err != nil {
+			t.Fatalf("PublishAPILogEvent() error = %v", err)
+		}
+	}
+
+	// Workers hand events to the batch without waiting for it to be posted
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := d.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	waitFor(t, func() bool { return len(rec.getBatches()) > 0 })
+
+	if batches := rec.getBatches(); len(batches) != 1 || len(batches[0]) != 20 {
+		t.Errorf("Expected a single batch of 20 events, got %d batches", len(batches))
+	}
+}
+
+func TestWebhookPublisher_CloseFlushesPendingBatch(t *testing.T) {
+	rec := &webhookRecorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	p, _ := NewWebhookPublisher(WebhookOptions{URL: srv.URL, BatchSize: 100, FlushInterval: time.Hour})
+	p.PublishAPILogEvent(context.Background(), newTestEvent("req-1"))
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if batches := rec.getBatches(); len(batches) != 1 {
+		t.Errorf("Expected pending batch to be posted on close, got %d batches", len(batches))
+	}
+
+	if err := p.PublishAPILogEvent(context.Background(), newTestEvent("req-2")); err != ErrPublisherClosed {
+		t.Errorf("Expected ErrPublisherClosed, got %v", err)
+	}
+}
+
+func TestWebhookPublisher_CapsPostsInFlight(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	inFlight, maxInFlight, posted := 0, 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+
+		<-release
+
+		mu.Lock()
+		inFlight--
+		posted++
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	p, _ := NewWebhookPublisher(WebhookOptions{URL: srv.URL, BatchSize: 1, MaxInFlight: 2})
+	for i := 0; i < 6; i++ {
+		p.PublishAPILogEvent(context.Background(), newTestEvent("req"))
+	}
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return inFlight == 2
+	})
+	close(release)
+	p.Close()
+
+	if maxInFlight != 2 || posted != 6 {
+		t.Errorf("Expected 6 batches posted at most 2 at a time, got %d batches and %d at a time", posted, maxInFlight)
+	}
+}
+
+func TestWebhookPublisher_PostsKeyBatchesInOrder(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []logger.APILogEvent
+		json.NewDecoder(r.Body).Decode(&batch)
+		// Give later batches a chance to overtake this one
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		for _, event := range batch {
+			got = append(got, event.RequestID.String)
+		}
+	}))
+	defer srv.Close()
+
+	p, _ := NewWebhookPublisher(WebhookOptions{
+		URL:         srv.URL,
+		BatchSize:   2,
+		MaxInFlight: 4,
+		OrderingKey: func(event logger.APILogEvent) string { return event.UserID.String },
+	})
+	for i := 0; i < 4; i++ {
+		for _, user := range []string{"user-1", "user-2"} {
+			event := newTestEvent(fmt.Sprintf("%s-%d", user, i))
+			event.UserID = null.StringFrom(user)
+			p.PublishAPILogEvent(context.Background(), event)
+		}
+	}
+	p.Close()
+
+	for _, user := range []string{"user-1", "user-2"} {
+		var order []string
+		for _, id := range got {
+			if strings.HasPrefix(id, user) {
+				order = append(order, id)
+			}
+		}
+		want := []string{user + "-0", user + "-1", user + "-2", user + "-3"}
+		if !reflect.DeepEqual(order, want) {
+			t.Errorf("Expected %s events posted in order %v, got %v", user, want, order)
+		}
+	}
+}
+
+func TestWebhookPublisher_FailsBatchesWhenQueueIsFull(t *testing.T) {
+	release := make(chan struct{})
+	received := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case received <- struct{}{}:
+		default:
+		}
+		<-release
+	}))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var failed []error
+	p, _ := NewWebhookPublisher(WebhookOptions{
+		URL:         srv.URL,
+		BatchSize:   1,
+		MaxInFlight: 1,
+		OnError: func(_ logger.APILogEvent, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failed = append(failed, err)
+		},
+	})
+
+	p.PublishAPILogEvent(context.Background(), newTestEvent("req-0"))
+	<-received
+	// The poster is busy, so only webhookQueuedBatches batches can wait for it
+	for i := 0; i < webhookQueuedBatches+1; i++ {
+		p.PublishAPILogEvent(context.Background(), newTestEvent("req"))
+	}
+
+	mu.Lock()
+	if len(failed) != 1 || !errors.Is(failed[0], ErrQueueFull) {
+		t.Errorf("Expected 1 event failed with ErrQueueFull, got %v", failed)
+	}
+	mu.Unlock()
+
+	close(release)
+	p.Close()
+}
+
+func TestWebhookPublisher_ShutdownCancelsPostsInFlight(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	var mu sync.Mutex
+	var failed []string
+	p, _ := NewWebhookPublisher(WebhookOptions{
+		URL:       srv.URL,
+		BatchSize: 100,
+		OnError: func(event logger.APILogEvent, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failed = append(failed, event.RequestID.String)
+		},
+	})
+	p.PublishAPILogEvent(context.Background(), newTestEvent("req-1"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := p.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected Shutdown to cancel the post, took %v", elapsed)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(failed) != 1 || failed[0] != "req-1" {
+		t.Errorf("Expected req-1 to be reported for the canceled post, got %v", failed)
+	}
+}
+
+func TestNewWebhookPublisher_RequiresURL(t *testing.T) {
+	if _, err := NewWebhookPublisher(WebhookOptions{}); err == nil {
+		t.Error("Expected error for empty URL")
+	}
+}
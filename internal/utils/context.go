@@ -1,18 +0,0 @@
-package utils
-
-import "context"
-
-const userIDKey contextKey = "userID"
-
-// SetUserID stores the user ID in the context
-func SetUserID(ctx context.Context, userID string) context.Context {
-	return context.WithValue(ctx, userIDKey, userID)
-}
-
-// GetUserID retrieves the user ID from the context
-func GetUserID(ctx context.Context) string {
-	if userID, ok := ctx.Value(userIDKey).(string); ok {
-		return userID
-	}
-	return ""
-}
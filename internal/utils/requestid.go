@@ -1,33 +0,0 @@
-package utils
-
-import (
-	"context"
-	"crypto/rand"
-	"encoding/hex"
-)
-
-type contextKey string
-
-const requestIDKey contextKey = "requestID"
-
-// GenerateRequestID generates a random request ID
-func GenerateRequestID() string {
-	b := make([]byte, 16)
-	if _, err := rand.Read(b); err != nil {
-		return ""
-	}
-	return hex.EncodeToString(b)
-}
-
-// SetRequestID stores the request ID in the context
-func SetRequestID(ctx context.Context, requestID string) context.Context {
-	return context.WithValue(ctx, requestIDKey, requestID)
-}
-
-// GetRequestID retrieves the request ID from the context
-func GetRequestID(ctx context.Context) string {
-	if requestID, ok := ctx.Value(requestIDKey).(string); ok {
-		return requestID
-	}
-	return ""
-}
@@ -0,0 +1,18 @@
+// Package apilog logs HTTP requests and responses as API log events
+//
+// LoggingMiddleware works with any net/http handler, the route of a request is
+// read with a RouteInfoExtractor (http.ServeMux patterns by default, see the
+// apilogmux package for gorilla/mux)
+package apilog
+
+import (
+	"context"
+
+	"api-pubsub-logger/pkg/logger"
+)
+
+// Publisher defines the interface for publishing API log events
+type Publisher interface {
+	PublishAPILogEvent(ctx context.Context, event logger.APILogEvent) error
+	Close() error
+}
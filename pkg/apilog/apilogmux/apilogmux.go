@@ -0,0 +1,32 @@
+// Package apilogmux adapts the apilog middleware to gorilla/mux routers
+package apilogmux
+
+import (
+	"net/http"
+
+	"api-pubsub-logger/pkg/apilog"
+
+	"github.com/gorilla/mux"
+)
+
+// RouteInfo extracts the route name, path template and version from the mux route of a request
+func RouteInfo(r *http.Request) apilog.RouteInfo {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return apilog.RouteInfo{}
+	}
+
+	template, _ := route.GetPathTemplate()
+	return apilog.RouteInfo{
+		Name:     route.GetName(),
+		Template: template,
+		Version:  apilog.VersionFromTemplate(template),
+	}
+}
+
+// LoggingMiddleware returns apilog.LoggingMiddleware reading routes from gorilla/mux,
+// it must be added with Router.Use so the route is matched before it runs
+func LoggingMiddleware(publisher apilog.Publisher, opts ...apilog.Option) mux.MiddlewareFunc {
+	opts = append([]apilog.Option{apilog.WithRouteInfo(RouteInfo)}, opts...)
+	return apilog.LoggingMiddleware(publisher, opts...)
+}
@@ -0,0 +1,152 @@
+package apilogmux
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"api-pubsub-logger/pkg/apilog"
+	"api-pubsub-logger/pkg/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// mockPublisher records the published events
+type mockPublisher struct {
+	mu              sync.Mutex
+	publishedEvents []logger.APILogEvent
+}
+
+func (m *mockPublisher) PublishAPILogEvent(ctx context.Context, event logger.APILogEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.publishedEvents = append(m.publishedEvents, event)
+	return nil
+}
+
+func (m *mockPublisher) Close() error {
+	return nil
+}
+
+func (m *mockPublisher) getEvents() []logger.APILogEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	events := make([]logger.APILogEvent, len(m.publishedEvents))
+	copy(events, m.publishedEvents)
+	return events
+}
+
+func TestRouteInfo(t *testing.T) {
+	tests := []struct {
+		name            string
+		pathTemplate    string
+		routeName       string
+		expectedVersion string
+		expectedName    string
+	}{
+		{
+			name:            "extracts v1 version",
+			pathTemplate:    "/v1/items",
+			routeName:       "list_items",
+			expectedVersion: "v1",
+			expectedName:    "list_items",
+		},
+		{
+			name:            "extracts v2 version",
+			pathTemplate:    "/v2/users/{id}",
+			routeName:       "get_user",
+			expectedVersion: "v2",
+			expectedName:    "get_user",
+		},
+		{
+			name:            "handles no version",
+			pathTemplate:    "/items",
+			routeName:       "items",
+			expectedVersion: "",
+			expectedName:    "items",
+		},
+		{
+			name:            "handles nil route",
+			pathTemplate:    "",
+			routeName:       "",
+			expectedVersion: "",
+			expectedName:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mux.NewRouter()
+			if tt.pathTemplate != "" {
+				r.Path(tt.pathTemplate).Name(tt.routeName)
+			}
+
+			var info apilog.RouteInfo
+			r.Use(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+					info = RouteInfo(req)
+				})
+			})
+
+			if tt.pathTemplate != "" {
+				r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tt.pathTemplate, nil))
+			} else {
+				// A request that was not routed by mux
+				info = RouteInfo(httptest.NewRequest("GET", "/unknown", nil))
+			}
+
+			if info.Name != tt.expectedName {
+				t.Errorf("Expected name = %v, got %v", tt.expectedName, info.Name)
+			}
+
+			if info.Version != tt.expectedVersion {
+				t.Errorf("Expected version = %v, got %v", tt.expectedVersion, info.Version)
+			}
+		})
+	}
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	mockClient := &mockPublisher{}
+	serviceName := "test-service"
+
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"result":"success"}`))
+	})
+
+	// Create a real mux router to test route extraction
+	r := mux.NewRouter()
+	r.Use(LoggingMiddleware(mockClient, apilog.WithServiceName(serviceName)))
+
+	// Add a versioned route with a name
+	v1 := r.PathPrefix("/v1").Subrouter()
+	v1.Methods("GET").Path("/items").Name("list_items").Handler(testHandler)
+
+	req := httptest.NewRequest("GET", "/v1/items", nil)
+	ctx := apilog.SetRequestID(req.Context(), "req-789")
+	req = req.WithContext(ctx)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	// Verify event was published
+	events := mockClient.getEvents()
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events))
+	}
+
+	event := events[0]
+
+	// Verify version is extracted
+	if event.Version != "v1" {
+		t.Errorf("Expected version = v1, got %v", event.Version)
+	}
+
+	// Verify name is extracted
+	if event.Name != "list_items" {
+		t.Errorf("Expected name = list_items, got %v", event.Name)
+	}
+}
@@ -0,0 +1,63 @@
+package apilog
+
+import (
+	"bytes"
+	"io"
+)
+
+// limitedBuffer keeps the first limit bytes written to it and discards the rest,
+// a non-positive limit keeps everything
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+// Write always reports len(p) written so that it can be used with io.TeeReader
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.limit > 0 && b.Len()+len(p) > b.limit {
+		b.Buffer.Write(p[:max(b.limit-b.Len(), 0)])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// full reports whether the buffer discards everything written to it
+func (b *limitedBuffer) full() bool {
+	return b.limit > 0 && b.Len() >= b.limit
+}
+
+// requestCapture tees the first bytes of a request body while the handler reads it
+// The part the handler does not read is not captured, so that rejecting a request early
+// does not wait for the client to upload its body
+type requestCapture struct {
+	io.ReadCloser
+	body limitedBuffer
+	// size counts the bytes read, captured or not
+	size int
+	eof  bool
+}
+
+func newRequestCapture(body io.ReadCloser, limit int) *requestCapture {
+	return &requestCapture{
+		ReadCloser: body,
+		body:       limitedBuffer{limit: limit},
+	}
+}
+
+func (c *requestCapture) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.size += n
+	c.body.Write(p[:n])
+	if err == io.EOF {
+		c.eof = true
+	}
+	return n, err
+}
+
+// totalSize returns the size of the whole body, as announced by the client when it was not all read
+func (c *requestCapture) totalSize(contentLength int64) int {
+	if !c.eof && contentLength > int64(c.size) {
+		return int(contentLength)
+	}
+	return c.size
+}
@@ -0,0 +1,147 @@
+package apilog
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimitedBuffer(t *testing.T) {
+	b := &limitedBuffer{limit: 5}
+
+	for _, s := range []string{"abc", "def", "ghi"} {
+		if n, err := b.Write([]byte(s)); n != len(s) || err != nil {
+			t.Fatalf("Write() = %d, %v", n, err)
+		}
+	}
+
+	if b.String() != "abcde" || !b.full() {
+		t.Errorf("Expected buffer = abcde and full, got %q", b.String())
+	}
+}
+
+func TestLoggingMiddleware_BoundedRequestCapture(t *testing.T) {
+	upload := strings.Repeat("x", 1000)
+
+	tests := []struct {
+		name        string
+		read        int64
+		wantCapture string
+	}{
+		{name: "handler streams the body", read: 1000, wantCapture: upload[:100]},
+		{name: "handler reads the start of the body", read: 50, wantCapture: upload[:50]},
+		{name: "handler ignores the body", read: 0, wantCapture: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received int64
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				received, _ = io.CopyN(io.Discard, r.Body, tt.read)
+				w.WriteHeader(http.StatusAccepted)
+			}
+
+			req := httptest.NewRequest("POST", "/v1/uploads", strings.NewReader(upload))
+			events := serveOnce(t, req, handler, WithBodyLimit(100))
+			if len(events) != 1 {
+				t.Fatalf("Expected 1 event, got %d", len(events))
+			}
+
+			if received != tt.read {
+				t.Errorf("Expected handler to read %d bytes, got %d", tt.read, received)
+			}
+
+			// Only what the handler read is captured, the size is the announced Content-Length
+			event := events[0]
+			if event.RequestBody.String != tt.wantCapture {
+				t.Errorf("Expected request body = %q, got %q", tt.wantCapture, event.RequestBody.String)
+			}
+			if !event.Truncated || event.RequestBodySize != 1000 || event.RequestBodyCapturedSize != len(tt.wantCapture) {
+				t.Errorf("Expected truncated body of 1000 bytes with %d captured, got %v, %d, %d",
+					len(tt.wantCapture), event.Truncated, event.RequestBodySize, event.RequestBodyCapturedSize)
+			}
+		})
+	}
+}
+
+func TestLoggingMiddleware_EarlyRejectionDoesNotWaitForBody(t *testing.T) {
+	mockClient := &mockPubSubClient{}
+	reject := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	srv := httptest.NewServer(LoggingMiddleware(mockClient)(reject))
+	defer srv.Close()
+
+	// Announce a 10MiB upload but only send its first byte
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "POST /v1/uploads HTTP/1.1\r\nHost: example.com\r\nContent-Length: 10485760\r\n\r\nx")
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("Expected the rejection before the upload, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status = 401, got %d", resp.StatusCode)
+	}
+
+	events := mockClient.getEvents()
+	if len(events) != 1 || events[0].RequestBody.Valid || events[0].RequestBodySize != 10485760 {
+		t.Errorf("Expected an event without the unread body, got %+v", events)
+	}
+}
+
+func TestLoggingMiddleware_BoundedResponseCapture(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 10; i++ {
+			w.Write([]byte(strings.Repeat("y", 100)))
+		}
+	}
+
+	events := serveOnce(t, httptest.NewRequest("GET", "/v1/items", nil), handler, WithBodyLimit(150))
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events))
+	}
+
+	event := events[0]
+	if len(event.ResponseBody.String) != 150 {
+		t.Errorf("Expected 150 bytes of response body, got %d", len(event.ResponseBody.String))
+	}
+	if !event.Truncated || event.ResponseBodySize != 1000 || event.ResponseBodyCapturedSize != 150 {
+		t.Errorf("Expected truncated body of 1000 bytes with 150 captured, got %v, %d, %d",
+			event.Truncated, event.ResponseBodySize, event.ResponseBodyCapturedSize)
+	}
+	if event.RequestBody.Valid || event.RequestBodySize != 0 {
+		t.Errorf("Expected no request body, got %+v", event)
+	}
+}
+
+func TestLoggingMiddleware_BodiesUnderLimit(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}
+
+	req := httptest.NewRequest("POST", "/v1/items", strings.NewReader(`{"name":"test"}`))
+	events := serveOnce(t, req, handler)
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events))
+	}
+
+	event := events[0]
+	if event.RequestBody.String != `{"name":"test"}` || event.ResponseBody.String != `{"name":"test"}` {
+		t.Errorf("Expected whole bodies, got %q and %q", event.RequestBody.String, event.ResponseBody.String)
+	}
+	if event.Truncated || event.RequestBodySize != 0 || event.ResponseBodySize != 0 {
+		t.Errorf("Expected bodies not to be truncated, got %+v", event)
+	}
+}
@@ -0,0 +1,135 @@
+package apilog
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ForwardedHeader is the header trusted proxies set with the client and proxy addresses
+type ForwardedHeader int
+
+const (
+	// HeaderXForwardedFor reads the X-Forwarded-For header
+	HeaderXForwardedFor ForwardedHeader = iota
+	// HeaderForwarded reads the Forwarded header (RFC 7239)
+	HeaderForwarded
+)
+
+// ParseForwardedHeader parses a forwarded header name (X-Forwarded-For or Forwarded)
+func ParseForwardedHeader(name string) (ForwardedHeader, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "x-forwarded-for":
+		return HeaderXForwardedFor, nil
+	case "forwarded":
+		return HeaderForwarded, nil
+	default:
+		return 0, fmt.Errorf("unknown forwarded header %q", name)
+	}
+}
+
+// ParseTrustedProxies parses CIDRs (e.g. 10.0.0.0/8) and addresses of trusted proxies, empty values are ignored
+func ParseTrustedProxies(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// clientIP returns the address of the client of a request
+// The hops of the forwarded header are only followed from a trusted peer, from the right
+// to the first address that is not a trusted proxy, so clients cannot spoof their address
+// The other header is never read, proxies may pass it through from the client unchanged
+func clientIP(r *http.Request, trusted []netip.Prefix, header ForwardedHeader) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	addr = addr.Unmap()
+	if !isTrustedProxy(addr, trusted) {
+		return addr.String()
+	}
+
+	hops := forwardedFor(r.Header, header)
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseForwardedAddr(hops[i])
+		if !ok {
+			// Obfuscated or unknown hops (e.g. for=unknown) end the chain at the last known proxy
+			break
+		}
+		addr = hop
+		if !isTrustedProxy(addr, trusted) {
+			break
+		}
+	}
+	return addr.String()
+}
+
+func isTrustedProxy(addr netip.Addr, trusted []netip.Prefix) bool {
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor returns the client and proxy addresses of the forwarded header,
+// Forwarded elements without a for parameter are returned empty
+func forwardedFor(header http.Header, name ForwardedHeader) []string {
+	if name != HeaderForwarded {
+		return strings.Split(strings.Join(header.Values("X-Forwarded-For"), ","), ",")
+	}
+
+	var hops []string
+	for _, element := range strings.Split(strings.Join(header.Values("Forwarded"), ","), ",") {
+		var hop string
+		for _, pair := range strings.Split(element, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(name, "for") {
+				hop = value
+			}
+		}
+		hops = append(hops, hop)
+	}
+	return hops
+}
+
+// parseForwardedAddr parses a hop address, optionally quoted, bracketed (IPv6) and with a port
+func parseForwardedAddr(value string) (netip.Addr, bool) {
+	value = strings.Trim(strings.TrimSpace(value), `"`)
+	if addrPort, err := netip.ParseAddrPort(value); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(value, "["), "]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
@@ -0,0 +1,236 @@
+package apilog
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		expected []netip.Prefix
+		wantErr  bool
+	}{
+		{
+			name:   "CIDRs and addresses",
+			values: []string{"10.0.0.0/8", " 192.168.1.7 ", "2001:db8::/32", ""},
+			expected: []netip.Prefix{
+				netip.MustParsePrefix("10.0.0.0/8"),
+				netip.MustParsePrefix("192.168.1.7/32"),
+				netip.MustParsePrefix("2001:db8::/32"),
+			},
+		},
+		{
+			name:     "masks host bits",
+			values:   []string{"172.16.5.4/12"},
+			expected: []netip.Prefix{netip.MustParsePrefix("172.16.0.0/12")},
+		},
+		{
+			name:     "unmaps IPv4-mapped addresses",
+			values:   []string{"::ffff:10.1.2.3"},
+			expected: []netip.Prefix{netip.MustParsePrefix("10.1.2.3/32")},
+		},
+		{name: "invalid address", values: []string{"proxy.internal"}, wantErr: true},
+		{name: "invalid CIDR", values: []string{"10.0.0.0/33"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefixes, err := ParseTrustedProxies(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error = %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(prefixes, tt.expected) {
+				t.Errorf("Expected prefixes = %v, got %v", tt.expected, prefixes)
+			}
+		})
+	}
+}
+
+func TestParseForwardedHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected ForwardedHeader
+		wantErr  bool
+	}{
+		{name: "", expected: HeaderXForwardedFor},
+		{name: "X-Forwarded-For", expected: HeaderXForwardedFor},
+		{name: "forwarded", expected: HeaderForwarded},
+		{name: "X-Real-IP", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, err := ParseForwardedHeader(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error = %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && header != tt.expected {
+				t.Errorf("Expected header = %v, got %v", tt.expected, header)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("2001:db8::/32")}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		trusted    []netip.Prefix
+		header     ForwardedHeader
+		expected   string
+	}{
+		{
+			name:       "peer address without trusted proxies",
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.7"},
+			expected:   "10.0.0.1",
+		},
+		{
+			name:       "headers from an untrusted peer are ignored",
+			remoteAddr: "198.51.100.2:5000",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.7"},
+			trusted:    trusted,
+			expected:   "198.51.100.2",
+		},
+		{
+			name:       "X-Forwarded-For from a trusted peer",
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.7"},
+			trusted:    trusted,
+			expected:   "203.0.113.7",
+		},
+		{
+			name:       "spoofed hops left of the first untrusted address are ignored",
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string]string{"X-Forwarded-For": "1.2.3.4, 203.0.113.7, 10.0.0.2"},
+			trusted:    trusted,
+			expected:   "203.0.113.7",
+		},
+		{
+			name:       "every hop trusted",
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string]string{"X-Forwarded-For": "10.0.0.3,10.0.0.2"},
+			trusted:    trusted,
+			expected:   "10.0.0.3",
+		},
+		{
+			name:       "forged Forwarded header is ignored by default",
+			remoteAddr: "10.0.0.1:5000",
+			headers: map[string]string{
+				"Forwarded":       "for=6.6.6.6",
+				"X-Forwarded-For": "198.51.100.9",
+			},
+			trusted:  trusted,
+			expected: "198.51.100.9",
+		},
+		{
+			name:       "forged X-Forwarded-For header is ignored with Forwarded",
+			remoteAddr: "10.0.0.1:5000",
+			headers: map[string]string{
+				"Forwarded":       `for="[2001:db8:cafe::17]:4711";proto=https, for=10.0.0.2`,
+				"X-Forwarded-For": "6.6.6.6",
+			},
+			trusted:  []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+			header:   HeaderForwarded,
+			expected: "2001:db8:cafe::17",
+		},
+		{
+			name:       "missing Forwarded header with Forwarded",
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string]string{"X-Forwarded-For": "6.6.6.6"},
+			trusted:    trusted,
+			header:     HeaderForwarded,
+			expected:   "10.0.0.1",
+		},
+		{
+			name:       "Forwarded with port and case-insensitive parameter",
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string]string{"Forwarded": `proto=http;For="203.0.113.7:8080"`},
+			trusted:    trusted,
+			header:     HeaderForwarded,
+			expected:   "203.0.113.7",
+		},
+		{
+			name:       "unknown hop ends the chain at the last proxy",
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string]string{"Forwarded": "for=unknown, for=10.0.0.2"},
+			trusted:    trusted,
+			header:     HeaderForwarded,
+			expected:   "10.0.0.2",
+		},
+		{
+			name:       "trusted IPv6 peer",
+			remoteAddr: "[2001:db8::1]:5000",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.7"},
+			trusted:    trusted,
+			expected:   "203.0.113.7",
+		},
+		{
+			name:       "IPv4-mapped peer",
+			remoteAddr: "[::ffff:10.0.0.1]:5000",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.7"},
+			trusted:    trusted,
+			expected:   "203.0.113.7",
+		},
+		{
+			name:       "unparsable peer address",
+			remoteAddr: "@",
+			trusted:    trusted,
+			expected:   "@",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v1/items", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			if ip := clientIP(req, tt.trusted, tt.header); ip != tt.expected {
+				t.Errorf("Expected client IP = %s, got %s", tt.expected, ip)
+			}
+		})
+	}
+}
+
+func TestLoggingMiddleware_NetworkMetadata(t *testing.T) {
+	req := httptest.NewRequest("POST", "https://api.example.com/v1/items", strings.NewReader(`{"name":"test"}`))
+	req.RemoteAddr = "10.0.0.1:5000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	req.Header.Set("Forwarded", "for=6.6.6.6")
+	req.Header.Set("User-Agent", "curl/8.5.0")
+	req.Header.Set("Referer", "https://example.com/items")
+	req.TLS.Version = tls.VersionTLS13
+
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"result":"0123456789"}`))
+	}
+
+	events := serveOnce(t, req, echo, WithTrustedProxies(netip.MustParsePrefix("10.0.0.0/8")), WithBodyLimit(4))
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events))
+	}
+
+	event := events[0]
+	if event.ClientIP != "203.0.113.7" || event.UserAgent != "curl/8.5.0" || event.Referer != "https://example.com/items" {
+		t.Errorf("Expected client metadata, got %+v", event)
+	}
+	if event.Protocol != "HTTP/1.1" || event.Host != "api.example.com" || event.TLSVersion != "TLS 1.3" {
+		t.Errorf("Expected connection metadata, got %+v", event)
+	}
+	if event.RequestBytes != 15 || event.ResponseBytes != 23 {
+		t.Errorf("Expected request_bytes = 15 and response_bytes = 23, got %d and %d", event.RequestBytes, event.ResponseBytes)
+	}
+}
@@ -0,0 +1,180 @@
+package apilog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// binaryBody replaces a binary body in the logs
+type binaryBody struct {
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	SHA256      string `json:"sha256"`
+}
+
+// formFile replaces a file uploaded with a multipart form in the logs
+type formFile struct {
+	Filename string `json:"filename"`
+	Size     int    `json:"size"`
+	SHA256   string `json:"sha256"`
+}
+
+// bodyForLog returns how a captured body is logged given the headers it was sent with:
+// text and JSON as-is, forms as JSON field maps (masked like any JSON body) and any other
+// content as its size and SHA-256 digest, gzip bodies are decoded first (up to limit bytes)
+// The size and digest are those of the captured bytes, which may be cut at the capture limit
+func bodyForLog(body []byte, header http.Header, limit int) []byte {
+	if len(body) == 0 {
+		return body
+	}
+
+	contentType := header.Get("Content-Type")
+	switch encoding := strings.ToLower(header.Get("Content-Encoding")); encoding {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		decoded, err := gunzip(body, limit)
+		if err != nil {
+			return digestBody(contentType, body)
+		}
+		body = decoded
+	default:
+		return digestBody(contentType, body)
+	}
+
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return digestBody(contentType, body)
+	}
+
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		return urlencodedFields(body)
+	case mediaType == "multipart/form-data":
+		return multipartFields(body, params["boundary"])
+	case isTextMediaType(mediaType):
+		return body
+	default:
+		return digestBody(mediaType, body)
+	}
+}
+
+// isTextMediaType reports whether a media type is logged as-is
+func isTextMediaType(mediaType string) bool {
+	if strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/x-ndjson", "application/xml", "application/javascript", "application/graphql":
+		return true
+	}
+	return false
+}
+
+// gunzip decodes at most limit bytes of a gzip body, a body cut by the capture limit
+// is decoded as far as possible
+func gunzip(body []byte, limit int) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var r io.Reader = zr
+	if limit > 0 {
+		r = io.LimitReader(zr, int64(limit))
+	}
+	decoded, err := io.ReadAll(r)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// urlencodedFields returns the fields of a form as a JSON object
+func urlencodedFields(body []byte) []byte {
+	values, err := url.ParseQuery(string(body))
+	if err != nil && len(values) == 0 {
+		return body
+	}
+
+	fields := make(map[string][]any, len(values))
+	for name, vs := range values {
+		for _, v := range vs {
+			fields[name] = append(fields[name], v)
+		}
+	}
+	return marshalFields(fields)
+}
+
+// multipartFields returns the fields of a multipart form as a JSON object, files are
+// replaced by their name, size and digest, a form cut by the capture limit keeps the
+// fields read until the cut
+func multipartFields(body []byte, boundary string) []byte {
+	if boundary == "" {
+		return digestBody("multipart/form-data", body)
+	}
+
+	fields := make(map[string][]any)
+	mr := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+
+		data, err := io.ReadAll(part)
+		if part.FileName() != "" {
+			sum := sha256.Sum256(data)
+			fields[part.FormName()] = append(fields[part.FormName()], formFile{
+				Filename: part.FileName(),
+				Size:     len(data),
+				SHA256:   hex.EncodeToString(sum[:]),
+			})
+		} else if err == nil {
+			fields[part.FormName()] = append(fields[part.FormName()], string(data))
+		}
+		if err != nil {
+			break
+		}
+	}
+	return marshalFields(fields)
+}
+
+// marshalFields encodes form fields as a JSON object, fields with a single value are not arrays
+func marshalFields(fields map[string][]any) []byte {
+	object := make(map[string]any, len(fields))
+	for name, values := range fields {
+		if len(values) == 1 {
+			object[name] = values[0]
+		} else {
+			object[name] = values
+		}
+	}
+
+	data, _ := json.Marshal(object)
+	return data
+}
+
+// digestBody returns the size and SHA-256 digest of a binary body as a JSON object
+func digestBody(contentType string, body []byte) []byte {
+	sum := sha256.Sum256(body)
+	data, _ := json.Marshal(binaryBody{
+		ContentType: contentType,
+		Size:        len(body),
+		SHA256:      hex.EncodeToString(sum[:]),
+	})
+	return data
+}
@@ -0,0 +1,133 @@
+package apilog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatalf("gzip Write() error = %v", err)
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestBodyForLog(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 24)...)
+	compressed := gzipped(t, `{"result":"ok"}`)
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	mw.WriteField("title", "Invoice")
+	mw.WriteField("tag", "a")
+	mw.WriteField("tag", "b")
+	fw, _ := mw.CreateFormFile("file", "invoice.pdf")
+	fw.Write([]byte("%PDF-1.7"))
+	mw.Close()
+
+	tests := []struct {
+		name     string
+		body     []byte
+		header   http.Header
+		expected string
+	}{
+		{
+			name:     "json as-is",
+			body:     []byte(`{"name":"test"}`),
+			header:   http.Header{"Content-Type": {"application/json; charset=utf-8"}},
+			expected: `{"name":"test"}`,
+		},
+		{
+			name:     "text without content type",
+			body:     []byte("hello"),
+			header:   http.Header{},
+			expected: "hello",
+		},
+		{
+			name:     "urlencoded form",
+			body:     []byte("name=Jane&password=secret&tag=a&tag=b"),
+			header:   http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+			expected: `{"name":"Jane","password":"secret","tag":["a","b"]}`,
+		},
+		{
+			name:     "multipart form",
+			body:     form.Bytes(),
+			header:   http.Header{"Content-Type": {mw.FormDataContentType()}},
+			expected: `{"file":{"filename":"invoice.pdf","size":8,"sha256":"` + sha256Hex([]byte("%PDF-1.7")) + `"},"tag":["a","b"],"title":"Invoice"}`,
+		},
+		{
+			name:     "gzip response",
+			body:     compressed,
+			header:   http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"gzip"}},
+			expected: `{"result":"ok"}`,
+		},
+		{
+			name:     "gzip response cut at the capture limit",
+			body:     compressed[:len(compressed)-8],
+			header:   http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"gzip"}},
+			expected: `{"result":"ok"}`,
+		},
+		{
+			name:     "unsupported encoding",
+			body:     []byte("br data"),
+			header:   http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"br"}},
+			expected: `{"content_type":"application/json","size":7,"sha256":"` + sha256Hex([]byte("br data")) + `"}`,
+		},
+		{
+			name:     "binary",
+			body:     png,
+			header:   http.Header{"Content-Type": {"image/png"}},
+			expected: `{"content_type":"image/png","size":32,"sha256":"` + sha256Hex(png) + `"}`,
+		},
+		{
+			name:     "sniffed binary",
+			body:     png,
+			header:   http.Header{},
+			expected: `{"content_type":"image/png","size":32,"sha256":"` + sha256Hex(png) + `"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(bodyForLog(tt.body, tt.header, 0)); got != tt.expected {
+				t.Errorf("Expected body = %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoggingMiddleware_MasksFormFields(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	req := httptest.NewRequest("POST", "/v1/login", strings.NewReader("email=jane%40example.com&remember=1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	events := serveOnce(t, req, handler)
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events))
+	}
+
+	expected := `{"email":"***REDACTED***","remember":"1"}`
+	if events[0].RequestBody.String != expected {
+		t.Errorf("Expected request body = %s, got %s", expected, events[0].RequestBody.String)
+	}
+}
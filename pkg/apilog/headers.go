@@ -0,0 +1,66 @@
+package apilog
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DefaultRedactedHeaders are always captured with a redacted value
+var DefaultRedactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// HeaderPolicy controls which request and response headers are captured
+type HeaderPolicy struct {
+	// Allow only captures these headers when set, every header is captured otherwise
+	Allow []string
+	// Deny never captures these headers
+	Deny []string
+	// Redact captures these headers with a redacted value, in addition to DefaultRedactedHeaders
+	Redact []string
+}
+
+// headerCapture is a HeaderPolicy with canonical header names
+type headerCapture struct {
+	allow  map[string]struct{}
+	deny   map[string]struct{}
+	redact map[string]struct{}
+}
+
+func newHeaderCapture(policy HeaderPolicy) *headerCapture {
+	return &headerCapture{
+		allow:  headerSet(policy.Allow),
+		deny:   headerSet(policy.Deny),
+		redact: headerSet(append(append([]string{}, DefaultRedactedHeaders...), policy.Redact...)),
+	}
+}
+
+// capture returns the headers allowed by the policy, multiple values are comma-separated
+func (c *headerCapture) capture(header http.Header) map[string]string {
+	var captured map[string]string
+	for name, values := range header {
+		name = http.CanonicalHeaderKey(name)
+		if _, ok := c.deny[name]; ok {
+			continue
+		}
+		if _, ok := c.allow[name]; len(c.allow) > 0 && !ok {
+			continue
+		}
+
+		if captured == nil {
+			captured = make(map[string]string)
+		}
+		if _, ok := c.redact[name]; ok {
+			captured[name] = redacted
+		} else {
+			captured[name] = strings.Join(values, ", ")
+		}
+	}
+	return captured
+}
+
+func headerSet(names []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		set[http.CanonicalHeaderKey(strings.TrimSpace(name))] = struct{}{}
+	}
+	return set
+}
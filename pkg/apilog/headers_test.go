@@ -0,0 +1,101 @@
+package apilog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestHeaderPolicy_Capture(t *testing.T) {
+	header := http.Header{
+		"Accept":          {"application/json"},
+		"Accept-Encoding": {"gzip", "br"},
+		"Authorization":   {"Bearer secret"},
+		"X-Api-Key":       {"key"},
+		"X-Internal":      {"1"},
+		"X-Session":       {"abc"},
+	}
+
+	tests := []struct {
+		name     string
+		policy   HeaderPolicy
+		expected map[string]string
+	}{
+		{
+			name: "captures every header with the defaults redacted",
+			expected: map[string]string{
+				"Accept":          "application/json",
+				"Accept-Encoding": "gzip, br",
+				"Authorization":   "***REDACTED***",
+				"X-Api-Key":       "***REDACTED***",
+				"X-Internal":      "1",
+				"X-Session":       "abc",
+			},
+		},
+		{
+			name:     "allowlist",
+			policy:   HeaderPolicy{Allow: []string{"accept", "authorization"}},
+			expected: map[string]string{"Accept": "application/json", "Authorization": "***REDACTED***"},
+		},
+		{
+			name:   "denylist and extra redacted headers",
+			policy: HeaderPolicy{Deny: []string{"X-Internal", "Authorization"}, Redact: []string{"x-session"}},
+			expected: map[string]string{
+				"Accept":          "application/json",
+				"Accept-Encoding": "gzip, br",
+				"X-Api-Key":       "***REDACTED***",
+				"X-Session":       "***REDACTED***",
+			},
+		},
+		{
+			name:     "nothing allowed",
+			policy:   HeaderPolicy{Allow: []string{"If-None-Match"}},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newHeaderCapture(tt.policy).capture(header)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected headers = %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoggingMiddleware_CapturesHeaders(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=60")
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		w.Write([]byte(`{}`))
+	}
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest("GET", "/v1/items", nil)
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Cookie", "session=abc")
+		return req
+	}
+
+	events := serveOnce(t, newRequest(), handler)
+	if len(events) != 1 || events[0].RequestHeaders != nil || events[0].ResponseHeaders != nil {
+		t.Errorf("Expected no headers without WithHeaderCapture, got %+v", events)
+	}
+
+	events = serveOnce(t, newRequest(), handler, WithHeaderCapture(HeaderPolicy{}))
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events))
+	}
+
+	expectedRequest := map[string]string{"Accept": "application/json", "Cookie": "***REDACTED***"}
+	if !reflect.DeepEqual(events[0].RequestHeaders, expectedRequest) {
+		t.Errorf("Expected request headers = %v, got %v", expectedRequest, events[0].RequestHeaders)
+	}
+
+	expectedResponse := map[string]string{"Content-Type": "application/json", "Cache-Control": "max-age=60", "Set-Cookie": "***REDACTED***"}
+	if !reflect.DeepEqual(events[0].ResponseHeaders, expectedResponse) {
+		t.Errorf("Expected response headers = %v, got %v", expectedResponse, events[0].ResponseHeaders)
+	}
+}
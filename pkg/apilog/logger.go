@@ -0,0 +1,166 @@
+package apilog
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net/http"
+
+	"api-pubsub-logger/pkg/logger"
+
+	"github.com/google/uuid"
+	"gopkg.in/guregu/null.v3"
+)
+
+// LoggingMiddleware logs HTTP requests and responses to the publisher
+// The publisher is called on the request path, so it should not block
+// (e.g. a dispatcher queueing events for the actual client)
+func LoggingMiddleware(publisher Publisher, opts ...Option) func(http.Handler) http.Handler {
+	o := newOptions(opts)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Check if the request should be skipped
+			route := o.routeInfo(r)
+			if !o.shouldLog(r, route) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			startTime := o.clock()
+
+			// Capture the start of the request body while the handler reads it
+			var request *requestCapture
+			if r.Body != nil && r.Body != http.NoBody {
+				request = newRequestCapture(r.Body, o.bodyLimit)
+				r.Body = request
+			}
+
+			// Create response recorder to capture response
+			recorder, rw := newResponseRecorder(w, o.bodyLimit)
+
+			// Call the next handler, a panic is recovered until the event is published
+			maxFrames := DefaultMaxStackFrames
+			if o.recovery != nil && o.recovery.MaxStackFrames > 0 {
+				maxFrames = o.recovery.MaxStackFrames
+			}
+			handlerPanic := serveRecovering(next, rw, r, maxFrames)
+			repanic := handlerPanic != nil &&
+				(o.recovery == nil || (o.recovery.RepanicOnAbort && handlerPanic.isAbort()))
+			if handlerPanic != nil && !repanic {
+				writePanicError(rw, recorder)
+			}
+
+			// Extract context values
+			ctx := r.Context()
+			requestID := GetRequestID(ctx)
+			userID := GetUserID(ctx)
+
+			// Routers like http.ServeMux only expose the route once they have handled the request
+			if route == (RouteInfo{}) {
+				route = o.routeInfo(r)
+			}
+
+			// Create API log event
+			logData := logger.APILogEvent{
+				RequestID:    null.NewString(requestID, len(requestID) > 0),
+				Service:      o.serviceName,
+				Method:       r.Method,
+				URL:          r.URL.String(),
+				ResponseCode: recorder.statusCode,
+				UserID:       null.NewString(userID, len(userID) > 0),
+				Version:      route.Version,
+				Name:         route.Name,
+				CreatedAt:    startTime,
+				Duration:     o.clock().Sub(startTime).Seconds(),
+				ResponseMode: recorder.mode,
+				ClientIP:     clientIP(r, o.proxies, o.forwarded),
+				UserAgent:    r.UserAgent(),
+				Referer:      r.Referer(),
+				Protocol:     r.Proto,
+				Host:         r.Host,
+				EventID:      uuid.NewString(),
+			}
+			if r.TLS != nil {
+				logData.TLSVersion = tls.VersionName(r.TLS.Version)
+			}
+
+			if handlerPanic != nil {
+				logData.Panic = fmt.Sprint(handlerPanic.value)
+				logData.PanicStack = handlerPanic.stack
+			}
+
+			// Drop sampled out events before doing the masking work, panics are always kept
+			if o.sampler != nil && handlerPanic == nil {
+				keep, rate := o.sampler.Sample(logData)
+				if !keep {
+					return
+				}
+				logData.SampleRate = rate
+			}
+
+			// Mask sensitive data in request and response bodies
+			var requestBody []byte
+			if request != nil {
+				requestBody = request.body.Bytes()
+				size := request.totalSize(r.ContentLength)
+				logData.RequestBytes = int64(size)
+				if size > len(requestBody) {
+					logData.Truncated = true
+					logData.RequestBodySize = size
+					logData.RequestBodyCapturedSize = len(requestBody)
+				}
+			}
+			responseBody := recorder.body.Bytes()
+			logData.ResponseBytes = int64(recorder.size)
+			switch {
+			case recorder.mode != "":
+				logData.ResponseBodySize = recorder.size
+			case recorder.size > len(responseBody):
+				logData.Truncated = true
+				logData.ResponseBodySize = recorder.size
+				logData.ResponseBodyCapturedSize = len(responseBody)
+			}
+			requestBody = bodyForLog(requestBody, r.Header, o.bodyLimit)
+			responseBody = bodyForLog(responseBody, recorder.Header(), o.bodyLimit)
+			maskedRequestBody := string(o.masker(requestBody))
+			maskedResponseBody := string(o.masker(responseBody))
+			logData.RequestBody = null.NewString(maskedRequestBody, len(maskedRequestBody) > 0)
+			logData.ResponseBody = null.NewString(maskedResponseBody, len(maskedResponseBody) > 0)
+
+			if o.headers != nil {
+				logData.RequestHeaders = o.headers.capture(r.Header)
+				logData.ResponseHeaders = o.headers.capture(recorder.Header())
+			}
+
+			for _, enrich := range o.enrichers {
+				enrich(r, &logData)
+			}
+
+			// Hand the event to the publisher using background context
+			// We use context.Background() instead of the request context because
+			// the request context gets canceled when the HTTP response is sent,
+			// but we want the publishing to complete independently
+			publish(context.Background(), publisher, logData)
+
+			if repanic {
+				panic(handlerPanic.value)
+			}
+		})
+	}
+}
+
+// LegacyLoggingMiddleware is the former LoggingMiddleware signature
+//
+// Deprecated: use LoggingMiddleware with WithServiceName
+func LegacyLoggingMiddleware(publisher Publisher, serviceName string) func(http.Handler) http.Handler {
+	return LoggingMiddleware(publisher, WithServiceName(serviceName))
+}
+
+// publish hands log data to the publisher
+func publish(ctx context.Context, client Publisher, logData logger.APILogEvent) {
+	if err := client.PublishAPILogEvent(ctx, logData); err != nil {
+		log.Printf("Failed to publish API log event: %v", err)
+	}
+}
@@ -1,4 +1,4 @@
-package middleware
+package apilog
 
 import (
 	"bytes"
@@ -7,12 +7,8 @@ import (
 	"net/http/httptest"
 	"sync"
 	"testing"
-	"time"
 
-	"api-pubsub-logger/internal/utils"
 	"api-pubsub-logger/pkg/logger"
-
-	"github.com/gorilla/mux"
 )
 
 // mockPubSubClient is a mock implementation of the pubsub client for testing
@@ -42,73 +38,6 @@ func (m *mockPubSubClient) getEvents() []logger.APILogEvent {
 	return events
 }
 
-func TestExtractRouteVersionAndName(t *testing.T) {
-	tests := []struct {
-		name            string
-		pathTemplate    string
-		routeName       string
-		expectedVersion string
-		expectedName    string
-	}{
-		{
-			name:            "extracts v1 version",
-			pathTemplate:    "/v1/items",
-			routeName:       "list_items",
-			expectedVersion: "v1",
-			expectedName:    "list_items",
-		},
-		{
-			name:            "extracts v2 version",
-			pathTemplate:    "/v2/users/{id}",
-			routeName:       "get_user",
-			expectedVersion: "v2",
-			expectedName:    "get_user",
-		},
-		{
-			name:            "handles no version",
-			pathTemplate:    "/items",
-			routeName:       "items",
-			expectedVersion: "",
-			expectedName:    "items",
-		},
-		{
-			name:            "handles nil route",
-			pathTemplate:    "",
-			routeName:       "",
-			expectedVersion: "",
-			expectedName:    "",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			r := mux.NewRouter()
-			if tt.pathTemplate != "" {
-				r.Path(tt.pathTemplate).Name(tt.routeName)
-			}
-
-			var route *mux.Route
-			if tt.pathTemplate != "" {
-				req := httptest.NewRequest("GET", tt.pathTemplate, nil)
-				var match mux.RouteMatch
-				if r.Match(req, &match) {
-					route = match.Route
-				}
-			}
-
-			name, version := extractRouteVersionAndName(route)
-
-			if name != tt.expectedName {
-				t.Errorf("Expected name = %v, got %v", tt.expectedName, name)
-			}
-
-			if version != tt.expectedVersion {
-				t.Errorf("Expected version = %v, got %v", tt.expectedVersion, version)
-			}
-		})
-	}
-}
-
 func TestLoggingMiddleware_SkipsHealthCheck(t *testing.T) {
 	mockClient := &mockPubSubClient{}
 	serviceName := "test-service"
@@ -118,16 +47,13 @@ func TestLoggingMiddleware_SkipsHealthCheck(t *testing.T) {
 		w.Write([]byte(`{"status":"ok"}`))
 	})
 
-	handler := LoggingMiddleware(mockClient, serviceName)(testHandler)
+	handler := LoggingMiddleware(mockClient, WithServiceName(serviceName))(testHandler)
 
 	req := httptest.NewRequest("GET", "/health", nil)
 	rr := httptest.NewRecorder()
 
 	handler.ServeHTTP(rr, req)
 
-	// Give some time for async publishing
-	time.Sleep(50 * time.Millisecond)
-
 	// Verify no events were published
 	events := mockClient.getEvents()
 	if len(events) != 0 {
@@ -145,24 +71,21 @@ func TestLoggingMiddleware_CapturesRequestResponse(t *testing.T) {
 		w.Write([]byte(`{"result":"success"}`))
 	})
 
-	handler := LoggingMiddleware(mockClient, serviceName)(testHandler)
+	handler := LoggingMiddleware(mockClient, WithServiceName(serviceName))(testHandler)
 
 	requestBody := `{"name":"test"}`
 	req := httptest.NewRequest("POST", "/v1/items", bytes.NewBufferString(requestBody))
 	req.Header.Set("Content-Type", "application/json")
 
 	// Set request ID and user ID in context
-	ctx := utils.SetRequestID(req.Context(), "req-123")
-	ctx = utils.SetUserID(ctx, "user-456")
+	ctx := SetRequestID(req.Context(), "req-123")
+	ctx = SetUserID(ctx, "user-456")
 	req = req.WithContext(ctx)
 
 	rr := httptest.NewRecorder()
 
 	handler.ServeHTTP(rr, req)
 
-	// Give some time for async publishing
-	time.Sleep(100 * time.Millisecond)
-
 	// Verify event was published
 	events := mockClient.getEvents()
 	if len(events) != 1 {
@@ -196,6 +119,10 @@ func TestLoggingMiddleware_CapturesRequestResponse(t *testing.T) {
 		t.Errorf("Expected user ID = user-456, got %v", event.UserID)
 	}
 
+	if event.EventID == "" {
+		t.Error("Expected an event ID to be assigned")
+	}
+
 	if event.Duration <= 0 {
 		t.Error("Expected duration > 0")
 	}
@@ -205,30 +132,18 @@ func TestLoggingMiddleware_CapturesVersionAndName(t *testing.T) {
 	mockClient := &mockPubSubClient{}
 	serviceName := "test-service"
 
-	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	// Wrap a plain ServeMux, its pattern is only known once it has routed the request
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/items", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(`{"result":"success"}`))
 	})
-
-	// Create a real mux router to test route extraction
-	r := mux.NewRouter()
-	r.Use(LoggingMiddleware(mockClient, serviceName))
-
-	// Add a versioned route with a name
-	v1 := r.PathPrefix("/v1").Subrouter()
-	v1.Methods("GET").Path("/items").Name("list_items").Handler(testHandler)
+	handler := LoggingMiddleware(mockClient, WithServiceName(serviceName))(mux)
 
 	req := httptest.NewRequest("GET", "/v1/items", nil)
-	ctx := utils.SetRequestID(req.Context(), "req-789")
-	req = req.WithContext(ctx)
-
 	rr := httptest.NewRecorder()
-	r.ServeHTTP(rr, req)
-
-	// Give some time for async publishing
-	time.Sleep(100 * time.Millisecond)
+	handler.ServeHTTP(rr, req)
 
-	// Verify event was published
 	events := mockClient.getEvents()
 	if len(events) != 1 {
 		t.Fatalf("Expected 1 event, got %d", len(events))
@@ -242,8 +157,8 @@ func TestLoggingMiddleware_CapturesVersionAndName(t *testing.T) {
 	}
 
 	// Verify name is extracted
-	if event.Name != "list_items" {
-		t.Errorf("Expected name = list_items, got %v", event.Name)
+	if event.Name != "GET /v1/items" {
+		t.Errorf("Expected name = GET /v1/items, got %v", event.Name)
 	}
 }
 
@@ -257,20 +172,17 @@ func TestLoggingMiddleware_MasksSensitiveData(t *testing.T) {
 		w.Write([]byte(`{"id":"123","email":"user@example.com","phone_number":"+1-555-1234"}`))
 	})
 
-	handler := LoggingMiddleware(mockClient, serviceName)(testHandler)
+	handler := LoggingMiddleware(mockClient, WithServiceName(serviceName))(testHandler)
 
 	requestBody := `{"email":"test@example.com","password":"secret123"}`
 	req := httptest.NewRequest("POST", "/v1/items", bytes.NewBufferString(requestBody))
-	ctx := utils.SetRequestID(req.Context(), "req-789")
+	ctx := SetRequestID(req.Context(), "req-789")
 	req = req.WithContext(ctx)
 
 	rr := httptest.NewRecorder()
 
 	handler.ServeHTTP(rr, req)
 
-	// Give some time for async publishing
-	time.Sleep(100 * time.Millisecond)
-
 	// Verify event was published
 	events := mockClient.getEvents()
 	if len(events) != 1 {
@@ -295,41 +207,3 @@ func TestLoggingMiddleware_MasksSensitiveData(t *testing.T) {
 		}
 	}
 }
-
-func TestResponseRecorder(t *testing.T) {
-	// Test that response recorder properly captures response
-	rr := &responseRecorder{
-		ResponseWriter: httptest.NewRecorder(),
-		body:           &bytes.Buffer{},
-		statusCode:     http.StatusOK,
-	}
-
-	testData := []byte("test response body")
-	n, err := rr.Write(testData)
-
-	if err != nil {
-		t.Fatalf("Write() error = %v", err)
-	}
-
-	if n != len(testData) {
-		t.Errorf("Write() wrote %d bytes, want %d", n, len(testData))
-	}
-
-	if rr.body.String() != string(testData) {
-		t.Errorf("Body = %v, want %v", rr.body.String(), string(testData))
-	}
-}
-
-func TestResponseRecorder_WriteHeader(t *testing.T) {
-	rr := &responseRecorder{
-		ResponseWriter: httptest.NewRecorder(),
-		body:           &bytes.Buffer{},
-		statusCode:     http.StatusOK,
-	}
-
-	rr.WriteHeader(http.StatusCreated)
-
-	if rr.statusCode != http.StatusCreated {
-		t.Errorf("StatusCode = %d, want %d", rr.statusCode, http.StatusCreated)
-	}
-}
@@ -0,0 +1,146 @@
+package apilog
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// redacted replaces masked values
+const redacted = "***REDACTED***"
+
+// Sensitive keys that should be masked in logs
+var sensitiveKeys = map[string]struct{}{
+	"email":        {},
+	"phone_number": {},
+	"password":     {},
+	"token":        {},
+	"api_key":      {},
+}
+
+// MaskSensitiveData recursively masks sensitive data in JSON objects
+// Data that is not valid JSON, e.g. a body cut at the capture limit, has the values of
+// sensitive keys masked in place by maskPartialJSON
+func MaskSensitiveData(data []byte) []byte {
+	var jsonData interface{}
+	if err := json.Unmarshal(data, &jsonData); err != nil {
+		return maskPartialJSON(data)
+	}
+
+	maskedData := maskJSON(jsonData)
+
+	result, err := json.Marshal(maskedData)
+	if err != nil {
+		return data
+	}
+	return result
+}
+
+// maskJSON handles objects (maps) and arrays recursively to mask sensitive data
+func maskJSON(data interface{}) interface{} {
+	switch v := data.(type) {
+	case map[string]interface{}: // Handle JSON objects
+		for key, val := range v {
+			if _, exists := sensitiveKeys[key]; exists {
+				v[key] = redacted
+			} else {
+				v[key] = maskJSON(val)
+			}
+		}
+	case []interface{}: // Handle JSON arrays
+		for i, val := range v {
+			v[i] = maskJSON(val)
+		}
+	}
+	return data
+}
+
+// maskPartialJSON masks the values following sensitive keys without parsing the whole document,
+// a value cut by the end of data is masked too
+func maskPartialJSON(data []byte) []byte {
+	var out []byte
+	last := 0
+	for i := 0; i < len(data); {
+		if data[i] != '"' {
+			i++
+			continue
+		}
+
+		end := skipJSONString(data, i)
+		key := data[i+1 : max(end-1, i+1)]
+		colon := skipJSONSpace(data, end)
+		if colon >= len(data) || data[colon] != ':' {
+			i = end
+			continue
+		}
+		if _, exists := sensitiveKeys[string(key)]; !exists {
+			i = colon + 1
+			continue
+		}
+
+		start := skipJSONSpace(data, colon+1)
+		if start >= len(data) {
+			break
+		}
+		valueEnd := skipJSONValue(data, start)
+		out = append(out, data[last:start]...)
+		out = append(out, '"')
+		out = append(out, redacted...)
+		out = append(out, '"')
+		last = valueEnd
+		i = valueEnd
+	}
+
+	if out == nil {
+		return data
+	}
+	return append(out, data[last:]...)
+}
+
+// skipJSONString returns the index after the string starting at data[start], or len(data) when it is cut
+func skipJSONString(data []byte, start int) int {
+	for i := start + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(data)
+}
+
+// skipJSONValue returns the index after the value starting at data[start], or len(data) when it is cut
+func skipJSONValue(data []byte, start int) int {
+	switch data[start] {
+	case '"':
+		return skipJSONString(data, start)
+	case '{', '[':
+		depth := 0
+		for i := start; i < len(data); i++ {
+			switch data[i] {
+			case '"':
+				i = skipJSONString(data, i) - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+		}
+		return len(data)
+	default:
+		if i := bytes.IndexAny(data[start:], ",}] \t\r\n"); i >= 0 {
+			return start + i
+		}
+		return len(data)
+	}
+}
+
+func skipJSONSpace(data []byte, i int) int {
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t' || data[i] == '\r' || data[i] == '\n') {
+		i++
+	}
+	return i
+}
@@ -1,4 +1,4 @@
-package utils
+package apilog
 
 import (
 	"encoding/json"
@@ -105,6 +105,43 @@ func TestMaskSensitiveData(t *testing.T) {
 	}
 }
 
+func TestMaskSensitiveData_PartialJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "masks values before the cut",
+			input:    `{"name":"John","email":"john@example.com","password": "secret","bio":"long`,
+			expected: `{"name":"John","email":"***REDACTED***","password": "***REDACTED***","bio":"long`,
+		},
+		{
+			name:     "masks a value cut by the limit",
+			input:    `{"name":"John","token":"abc\"de`,
+			expected: `{"name":"John","token":"***REDACTED***"`,
+		},
+		{
+			name:     "masks nested and non-string values",
+			input:    `[{"api_key":{"id":1,"secret":"x"},"phone_number":5551234,"user":{"email":"a@b`,
+			expected: `[{"api_key":"***REDACTED***","phone_number":"***REDACTED***","user":{"email":"***REDACTED***"`,
+		},
+		{
+			name:     "leaves sensitive words outside keys",
+			input:    `{"note":"email: password","items":[1,2`,
+			expected: `{"note":"email: password","items":[1,2`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := string(MaskSensitiveData([]byte(tt.input))); result != tt.expected {
+				t.Errorf("MaskSensitiveData() = %s, want %s", result, tt.expected)
+			}
+		})
+	}
+}
+
 func TestMaskJSON(t *testing.T) {
 	tests := []struct {
 		name     string
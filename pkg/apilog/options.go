@@ -0,0 +1,174 @@
+package apilog
+
+import (
+	"net/http"
+	"net/netip"
+	"time"
+
+	"api-pubsub-logger/pkg/logger"
+)
+
+// DefaultBodyLimit is the number of bytes of the request and response bodies captured by default
+const DefaultBodyLimit = 1 << 20
+
+// Option configures LoggingMiddleware
+type Option func(*options)
+
+// Masker masks sensitive data in a request or response body
+type Masker func(body []byte) []byte
+
+// Skipper reports whether a request should not be logged
+type Skipper func(r *http.Request) bool
+
+// Enricher adds fields to the API log event of a request before it is published
+type Enricher func(r *http.Request, event *logger.APILogEvent)
+
+type options struct {
+	serviceName  string
+	masker       Masker
+	skippers     []Skipper
+	skipRules    []Rule
+	includeRules []Rule
+	bodyLimit    int
+	enrichers    []Enricher
+	clock        func() time.Time
+	sampler      *Sampler
+	routeInfo    RouteInfoExtractor
+	headers      *headerCapture
+	recovery     *RecoveryOptions
+	proxies      []netip.Prefix
+	forwarded    ForwardedHeader
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		masker:    MaskSensitiveData,
+		skipRules: DefaultSkipRules,
+		bodyLimit: DefaultBodyLimit,
+		clock:     time.Now,
+		routeInfo: PatternRouteInfo,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithServiceName sets the service name of the events
+func WithServiceName(name string) Option {
+	return func(o *options) {
+		o.serviceName = name
+	}
+}
+
+// WithMasker replaces MaskSensitiveData for masking bodies
+func WithMasker(masker Masker) Option {
+	return func(o *options) {
+		o.masker = masker
+	}
+}
+
+// WithSkipper adds a skipper, requests are skipped when any skipper or skip rule matches
+func WithSkipper(skipper Skipper) Option {
+	return func(o *options) {
+		o.skippers = append(o.skippers, skipper)
+	}
+}
+
+// WithSkipRules replaces DefaultSkipRules, requests matching one of the rules are not logged
+func WithSkipRules(rules ...Rule) Option {
+	return func(o *options) {
+		o.skipRules = rules
+	}
+}
+
+// WithIncludeRules only logs the requests matching one of the rules (and none of the skip rules)
+func WithIncludeRules(rules ...Rule) Option {
+	return func(o *options) {
+		o.includeRules = rules
+	}
+}
+
+// WithBodyLimit replaces DefaultBodyLimit, only the first limit bytes of the request and response
+// bodies are captured and logged (before masking), a non-positive limit captures whole bodies
+func WithBodyLimit(limit int) Option {
+	return func(o *options) {
+		o.bodyLimit = limit
+	}
+}
+
+// WithEnricher adds an enricher, enrichers run in order once the event is built
+func WithEnricher(enricher Enricher) Option {
+	return func(o *options) {
+		o.enrichers = append(o.enrichers, enricher)
+	}
+}
+
+// WithClock replaces time.Now for the event creation time and duration (e.g. in tests)
+func WithClock(clock func() time.Time) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithSampler only publishes the events kept by the sampler and records their sample rate
+func WithSampler(sampler *Sampler) Option {
+	return func(o *options) {
+		o.sampler = sampler
+	}
+}
+
+// WithHeaderCapture records the request and response headers allowed by the policy,
+// DefaultRedactedHeaders are always redacted
+func WithHeaderCapture(policy HeaderPolicy) Option {
+	return func(o *options) {
+		o.headers = newHeaderCapture(policy)
+	}
+}
+
+// WithRecovery recovers handler panics with a 500 JSON error, without it panics are published
+// and then propagated to the server
+func WithRecovery(opts RecoveryOptions) Option {
+	return func(o *options) {
+		o.recovery = &opts
+	}
+}
+
+// WithTrustedProxies resolves the client IP from the forwarded header set by these proxies,
+// without it the client IP is the peer address
+func WithTrustedProxies(proxies ...netip.Prefix) Option {
+	return func(o *options) {
+		o.proxies = proxies
+	}
+}
+
+// WithForwardedHeader sets the header read from trusted proxies, X-Forwarded-For by default
+func WithForwardedHeader(header ForwardedHeader) Option {
+	return func(o *options) {
+		o.forwarded = header
+	}
+}
+
+// WithRouteInfo replaces PatternRouteInfo for extracting the route of a request,
+// used by the route rules and for the event name and version
+func WithRouteInfo(extractor RouteInfoExtractor) Option {
+	return func(o *options) {
+		o.routeInfo = extractor
+	}
+}
+
+// shouldLog applies the include rules, skip rules and skippers to a request
+func (o *options) shouldLog(r *http.Request, route RouteInfo) bool {
+	if len(o.includeRules) > 0 && !matchesAny(o.includeRules, r, route) {
+		return false
+	}
+	if matchesAny(o.skipRules, r, route) {
+		return false
+	}
+	for _, skip := range o.skippers {
+		if skip(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package apilog
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"api-pubsub-logger/pkg/logger"
+)
+
+// serveOnce runs a request through the logging middleware and returns the published events
+func serveOnce(t *testing.T, req *http.Request, handler http.HandlerFunc, opts ...Option) []logger.APILogEvent {
+	t.Helper()
+
+	mockClient := &mockPubSubClient{}
+	LoggingMiddleware(mockClient, opts...)(handler).ServeHTTP(httptest.NewRecorder(), req)
+	return mockClient.getEvents()
+}
+
+func TestLoggingMiddleware_Options(t *testing.T) {
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte(`{"result":"0123456789"}`))
+	}
+	newRequest := func() *http.Request {
+		return httptest.NewRequest("POST", "/v1/items", bytes.NewBufferString(`{"name":"test"}`))
+	}
+
+	t.Run("WithServiceName", func(t *testing.T) {
+		events := serveOnce(t, newRequest(), echo, WithServiceName("orders"))
+		if len(events) != 1 || events[0].Service != "orders" {
+			t.Errorf("Expected service = orders, got %+v", events)
+		}
+	})
+
+	t.Run("WithMasker", func(t *testing.T) {
+		masker := func(body []byte) []byte { return bytes.ToUpper(body) }
+		events := serveOnce(t, newRequest(), echo, WithMasker(masker))
+		if len(events) != 1 || events[0].RequestBody.String != `{"NAME":"TEST"}` {
+			t.Errorf("Expected masked request body, got %+v", events)
+		}
+	})
+
+	t.Run("WithSkipper", func(t *testing.T) {
+		skipper := func(r *http.Request) bool { return r.Method == http.MethodPost }
+		if events := serveOnce(t, newRequest(), echo, WithSkipper(skipper)); len(events) != 0 {
+			t.Errorf("Expected request to be skipped, got %d events", len(events))
+		}
+	})
+
+	t.Run("WithBodyLimit", func(t *testing.T) {
+		events := serveOnce(t, newRequest(), echo, WithBodyLimit(10))
+		if len(events) != 1 {
+			t.Fatalf("Expected 1 event, got %d", len(events))
+		}
+		event := events[0]
+		if event.ResponseBody.String != `{"result":` || !event.Truncated || event.ResponseBodySize != 23 {
+			t.Errorf("Expected truncated response body, got %+v", event)
+		}
+	})
+
+	t.Run("WithEnricher", func(t *testing.T) {
+		enricher := func(r *http.Request, event *logger.APILogEvent) {
+			event.Name = strings.ToLower(r.Method) + "_items"
+		}
+		events := serveOnce(t, newRequest(), echo, WithEnricher(enricher))
+		if len(events) != 1 || events[0].Name != "post_items" {
+			t.Errorf("Expected enriched name = post_items, got %+v", events)
+		}
+	})
+
+	t.Run("WithClock", func(t *testing.T) {
+		start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		now := start
+		clock := func() time.Time {
+			current := now
+			now = now.Add(250 * time.Millisecond)
+			return current
+		}
+
+		events := serveOnce(t, newRequest(), echo, WithClock(clock))
+		if len(events) != 1 {
+			t.Fatalf("Expected 1 event, got %d", len(events))
+		}
+		if !events[0].CreatedAt.Equal(start) || events[0].Duration != 0.25 {
+			t.Errorf("Expected created_at = %v and duration = 0.25, got %v and %v", start, events[0].CreatedAt, events[0].Duration)
+		}
+	})
+}
+
+func TestLegacyLoggingMiddleware(t *testing.T) {
+	mockClient := &mockPubSubClient{}
+	handler := LegacyLoggingMiddleware(mockClient, "test-service")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/items", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+
+	events := mockClient.getEvents()
+	if len(events) != 1 || events[0].Service != "test-service" {
+		t.Errorf("Expected one event for test-service, got %+v", events)
+	}
+}
@@ -0,0 +1,146 @@
+package apilog
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+
+	"api-pubsub-logger/pkg/logger"
+)
+
+// responseRecorder is a wrapper for http.ResponseWriter to capture response data
+// Once the response is streamed or hijacked its body is no longer buffered
+type responseRecorder struct {
+	http.ResponseWriter
+	body        *limitedBuffer
+	statusCode  int
+	wroteHeader bool
+	// size counts the body bytes written, buffered or not
+	size int
+	// mode is one of the logger.ResponseMode values once the body is no longer buffered
+	mode string
+}
+
+// newResponseRecorder returns the recorder of w, buffering the first limit bytes of the body,
+// and the writer to pass to the handler, which only implements the optional http.Flusher,
+// http.Hijacker and io.ReaderFrom interfaces that w implements
+func newResponseRecorder(w http.ResponseWriter, limit int) (*responseRecorder, http.ResponseWriter) {
+	rw := &responseRecorder{
+		ResponseWriter: w,
+		body:           &limitedBuffer{limit: limit},
+		statusCode:     http.StatusOK,
+	}
+
+	_, flusher := w.(http.Flusher)
+	_, hijacker := w.(http.Hijacker)
+	_, readerFrom := w.(io.ReaderFrom)
+
+	switch {
+	case flusher && hijacker && readerFrom:
+		return rw, struct {
+			unwrapper
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{rw, rw, rw, rw}
+	case flusher && hijacker:
+		return rw, struct {
+			unwrapper
+			http.Flusher
+			http.Hijacker
+		}{rw, rw, rw}
+	case flusher && readerFrom:
+		return rw, struct {
+			unwrapper
+			http.Flusher
+			io.ReaderFrom
+		}{rw, rw, rw}
+	case hijacker && readerFrom:
+		return rw, struct {
+			unwrapper
+			http.Hijacker
+			io.ReaderFrom
+		}{rw, rw, rw}
+	case flusher:
+		return rw, struct {
+			unwrapper
+			http.Flusher
+		}{rw, rw}
+	case hijacker:
+		return rw, struct {
+			unwrapper
+			http.Hijacker
+		}{rw, rw}
+	case readerFrom:
+		return rw, struct {
+			unwrapper
+			io.ReaderFrom
+		}{rw, rw}
+	default:
+		return rw, struct{ unwrapper }{rw}
+	}
+}
+
+// unwrapper is a ResponseWriter that http.ResponseController can unwrap
+type unwrapper interface {
+	http.ResponseWriter
+	Unwrap() http.ResponseWriter
+}
+
+func (rw *responseRecorder) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(b)
+	rw.size += n
+	if rw.mode == "" {
+		rw.body.Write(b[:n])
+	}
+	return n, err
+}
+
+func (rw *responseRecorder) WriteHeader(statusCode int) {
+	if !rw.wroteHeader && statusCode >= 200 {
+		rw.statusCode = statusCode
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Flush marks the response as streamed and flushes the underlying writer
+func (rw *responseRecorder) Flush() {
+	rw.wroteHeader = true
+	rw.stopBuffering(logger.ResponseModeStreamed)
+	rw.ResponseWriter.(http.Flusher).Flush()
+}
+
+// Hijack marks the response as hijacked and hands the connection over to the handler
+func (rw *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := rw.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		rw.stopBuffering(logger.ResponseModeHijacked)
+	}
+	return conn, brw, err
+}
+
+// ReadFrom copies src with the underlying writer, src is only wrapped while the body is buffered
+// so that optimizations like sendfile still apply to streamed responses and full buffers
+func (rw *responseRecorder) ReadFrom(src io.Reader) (int64, error) {
+	rw.wroteHeader = true
+	if rw.mode == "" && !rw.body.full() {
+		src = io.TeeReader(src, rw.body)
+	}
+	n, err := rw.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
+	rw.size += int(n)
+	return n, err
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (rw *responseRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// stopBuffering drops the buffered body, the response is logged with its mode and size only
+func (rw *responseRecorder) stopBuffering(mode string) {
+	rw.mode = mode
+	rw.body.Reset()
+}
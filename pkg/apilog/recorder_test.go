@@ -0,0 +1,195 @@
+package apilog
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"api-pubsub-logger/pkg/logger"
+)
+
+// fullWriter implements every optional interface of http.ResponseWriter
+type fullWriter struct {
+	*httptest.ResponseRecorder
+	readFrom bool
+}
+
+func (w *fullWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	server, client := net.Pipe()
+	client.Close()
+	return server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)), nil
+}
+
+func (w *fullWriter) ReadFrom(src io.Reader) (int64, error) {
+	w.readFrom = true
+	return io.Copy(w.ResponseRecorder, src)
+}
+
+func TestNewResponseRecorder_PreservesInterfaces(t *testing.T) {
+	tests := []struct {
+		name           string
+		writer         http.ResponseWriter
+		wantFlusher    bool
+		wantHijacker   bool
+		wantReaderFrom bool
+	}{
+		{name: "plain writer", writer: struct{ http.ResponseWriter }{httptest.NewRecorder()}},
+		{name: "flusher", writer: httptest.NewRecorder(), wantFlusher: true},
+		{name: "all interfaces", writer: &fullWriter{ResponseRecorder: httptest.NewRecorder()}, wantFlusher: true, wantHijacker: true, wantReaderFrom: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, rw := newResponseRecorder(tt.writer, 0)
+
+			if _, ok := rw.(http.Flusher); ok != tt.wantFlusher {
+				t.Errorf("Expected http.Flusher = %v, got %v", tt.wantFlusher, ok)
+			}
+			if _, ok := rw.(http.Hijacker); ok != tt.wantHijacker {
+				t.Errorf("Expected http.Hijacker = %v, got %v", tt.wantHijacker, ok)
+			}
+			if _, ok := rw.(io.ReaderFrom); ok != tt.wantReaderFrom {
+				t.Errorf("Expected io.ReaderFrom = %v, got %v", tt.wantReaderFrom, ok)
+			}
+			if u, ok := rw.(interface{ Unwrap() http.ResponseWriter }); !ok || u.Unwrap() != tt.writer {
+				t.Error("Expected the recorder to unwrap to the underlying writer")
+			}
+		})
+	}
+}
+
+func TestLoggingMiddleware_StreamedResponse(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: 1\n\n"))
+		// Flushing through a ResponseController reaches the recorder too
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Flush() error = %v", err)
+		}
+		w.Write([]byte("data: 2\n\n"))
+	}
+
+	events := serveOnce(t, httptest.NewRequest("GET", "/v1/events", nil), handler)
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events))
+	}
+
+	event := events[0]
+	if event.ResponseMode != logger.ResponseModeStreamed {
+		t.Errorf("Expected response mode = %v, got %v", logger.ResponseModeStreamed, event.ResponseMode)
+	}
+	if event.ResponseBody.Valid {
+		t.Errorf("Expected no response body, got %q", event.ResponseBody.String)
+	}
+	if event.ResponseBodySize != 18 {
+		t.Errorf("Expected response body size = 18, got %d", event.ResponseBodySize)
+	}
+}
+
+func TestLoggingMiddleware_HijackedResponse(t *testing.T) {
+	mockClient := &mockPubSubClient{}
+	handler := LoggingMiddleware(mockClient)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("Hijack() error = %v", err)
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+		brw.Flush()
+	}))
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("GET /v1/socket HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n"))
+	status, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || !strings.Contains(status, "101") {
+		t.Fatalf("Expected 101 response, got %q (%v)", status, err)
+	}
+
+	// The event is published once the handler returns, after the client got its response
+	deadline := time.Now().Add(time.Second)
+	for len(mockClient.getEvents()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the event")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	event := mockClient.getEvents()[0]
+	if event.ResponseMode != logger.ResponseModeHijacked {
+		t.Errorf("Expected response mode = %v, got %v", logger.ResponseModeHijacked, event.ResponseMode)
+	}
+	if event.ResponseBody.Valid {
+		t.Errorf("Expected no response body, got %q", event.ResponseBody.String)
+	}
+}
+
+func TestResponseRecorder(t *testing.T) {
+	// Test that response recorder properly captures response
+	rr := &responseRecorder{
+		ResponseWriter: httptest.NewRecorder(),
+		body:           &limitedBuffer{},
+		statusCode:     http.StatusOK,
+	}
+
+	testData := []byte("test response body")
+	n, err := rr.Write(testData)
+
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if n != len(testData) {
+		t.Errorf("Write() wrote %d bytes, want %d", n, len(testData))
+	}
+
+	if rr.body.String() != string(testData) {
+		t.Errorf("Body = %v, want %v", rr.body.String(), string(testData))
+	}
+}
+
+func TestResponseRecorder_WriteHeader(t *testing.T) {
+	rr := &responseRecorder{
+		ResponseWriter: httptest.NewRecorder(),
+		body:           &limitedBuffer{},
+		statusCode:     http.StatusOK,
+	}
+
+	rr.WriteHeader(http.StatusCreated)
+
+	if rr.statusCode != http.StatusCreated {
+		t.Errorf("StatusCode = %d, want %d", rr.statusCode, http.StatusCreated)
+	}
+}
+
+func TestResponseRecorder_ReadFrom(t *testing.T) {
+	w := &fullWriter{ResponseRecorder: httptest.NewRecorder()}
+	recorder, rw := newResponseRecorder(w, 0)
+
+	n, err := rw.(io.ReaderFrom).ReadFrom(strings.NewReader("file contents"))
+	if err != nil || n != 13 {
+		t.Fatalf("ReadFrom() = %d, %v", n, err)
+	}
+
+	if !w.readFrom {
+		t.Error("Expected the underlying ReadFrom to be used")
+	}
+	if w.Body.String() != "file contents" || recorder.body.String() != "file contents" {
+		t.Errorf("Expected body to be written and recorded, got %q and %q", w.Body.String(), recorder.body.String())
+	}
+	if recorder.size != 13 {
+		t.Errorf("Expected size = 13, got %d", recorder.size)
+	}
+}
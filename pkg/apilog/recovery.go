@@ -0,0 +1,85 @@
+package apilog
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+)
+
+// DefaultMaxStackFrames is the number of stack frames recorded for a panic by default
+const DefaultMaxStackFrames = 32
+
+// panicErrorBody is the response sent for a recovered panic
+const panicErrorBody = `{"error":"internal server error"}`
+
+// RecoveryOptions controls how LoggingMiddleware recovers from handler panics
+type RecoveryOptions struct {
+	// RepanicOnAbort panics again with http.ErrAbortHandler once the event is published,
+	// so the server aborts the response as the handler intended instead of sending a 500 error
+	RepanicOnAbort bool
+	// MaxStackFrames is the number of stack frames recorded, DefaultMaxStackFrames when zero
+	MaxStackFrames int
+}
+
+// handlerPanic is a panic recovered from a handler
+type handlerPanic struct {
+	value any
+	stack string
+}
+
+// serveRecovering calls next and returns the panic it recovered from, if any
+func serveRecovering(next http.Handler, w http.ResponseWriter, r *http.Request, maxFrames int) (p *handlerPanic) {
+	defer func() {
+		if v := recover(); v != nil {
+			p = &handlerPanic{value: v, stack: panicStack(maxFrames)}
+		}
+	}()
+
+	next.ServeHTTP(w, r)
+	return nil
+}
+
+// panicStack returns the stack of the panicking handler, from the panic up to serveRecovering,
+// with at most maxFrames frames
+func panicStack(maxFrames int) string {
+	pcs := make([]uintptr, 64+maxFrames)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(1, pcs)])
+
+	var b strings.Builder
+	inHandler := false
+	for n := 0; n < maxFrames; {
+		frame, more := frames.Next()
+		switch {
+		case strings.HasSuffix(frame.Function, "apilog.serveRecovering"):
+			return b.String()
+		case frame.Function == "runtime.gopanic":
+			inHandler = true
+		case inHandler:
+			fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+			n++
+		}
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+// isAbort reports whether a handler panicked to abort the response
+func (p *handlerPanic) isAbort() bool {
+	err, ok := p.value.(error)
+	return ok && err == http.ErrAbortHandler
+}
+
+// writePanicError sends the 500 error of a recovered panic unless the response was already started
+func writePanicError(w http.ResponseWriter, recorder *responseRecorder) {
+	if recorder.wroteHeader || recorder.mode != "" {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte(panicErrorBody))
+}
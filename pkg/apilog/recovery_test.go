@@ -0,0 +1,126 @@
+package apilog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// panickingHandler panics with value, after writing a partial response when partial is set
+func panickingHandler(value any, partial bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if partial {
+			w.Write([]byte(`{"items":[`))
+		}
+		panic(value)
+	}
+}
+
+// servePanicking serves a request and returns the response, the panics of the published events and the propagated panic
+func servePanicking(handler http.Handler, opts ...Option) (rr *httptest.ResponseRecorder, events []string, propagated any) {
+	mockClient := &mockPubSubClient{}
+	rr = httptest.NewRecorder()
+	func() {
+		defer func() { propagated = recover() }()
+		LoggingMiddleware(mockClient, opts...)(handler).ServeHTTP(rr, httptest.NewRequest("GET", "/v1/items", nil))
+	}()
+
+	for _, event := range mockClient.getEvents() {
+		events = append(events, event.Panic)
+	}
+	return rr, events, propagated
+}
+
+func TestLoggingMiddleware_RecoversPanics(t *testing.T) {
+	mockClient := &mockPubSubClient{}
+	handler := LoggingMiddleware(mockClient, WithRecovery(RecoveryOptions{}))(panickingHandler("boom", false))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/items", nil))
+
+	if rr.Code != http.StatusInternalServerError || rr.Body.String() != panicErrorBody {
+		t.Errorf("Expected 500 JSON error, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected JSON content type, got %q", rr.Header().Get("Content-Type"))
+	}
+
+	events := mockClient.getEvents()
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events))
+	}
+
+	event := events[0]
+	if event.Panic != "boom" || event.ResponseCode != http.StatusInternalServerError || event.ResponseBody.String != panicErrorBody {
+		t.Errorf("Expected panic event with the 500 error, got %+v", event)
+	}
+	if !strings.HasPrefix(event.PanicStack, "api-pubsub-logger/pkg/apilog.panickingHandler.func") {
+		t.Errorf("Expected stack to start at the panicking handler, got %s", event.PanicStack)
+	}
+	if strings.Contains(event.PanicStack, "runtime.gopanic") || strings.Contains(event.PanicStack, "serveRecovering") {
+		t.Errorf("Expected stack to be trimmed to the handler, got %s", event.PanicStack)
+	}
+}
+
+func TestLoggingMiddleware_PanicPropagation(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         any
+		opts          []Option
+		wantCode      int
+		wantPropagate bool
+	}{
+		{name: "propagated without recovery", value: "boom", wantCode: http.StatusOK, wantPropagate: true},
+		{name: "abort recovered", value: http.ErrAbortHandler, opts: []Option{WithRecovery(RecoveryOptions{})}, wantCode: http.StatusInternalServerError},
+		{name: "abort propagated", value: http.ErrAbortHandler, opts: []Option{WithRecovery(RecoveryOptions{RepanicOnAbort: true})}, wantCode: http.StatusOK, wantPropagate: true},
+		{name: "other panics recovered", value: "boom", opts: []Option{WithRecovery(RecoveryOptions{RepanicOnAbort: true})}, wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr, events, propagated := servePanicking(panickingHandler(tt.value, false), tt.opts...)
+
+			if (propagated != nil) != tt.wantPropagate || (tt.wantPropagate && propagated != tt.value) {
+				t.Errorf("Expected propagated panic = %v, got %v", tt.wantPropagate, propagated)
+			}
+			if rr.Code != tt.wantCode {
+				t.Errorf("Expected status = %d, got %d", tt.wantCode, rr.Code)
+			}
+			if len(events) != 1 || events[0] == "" {
+				t.Errorf("Expected one event recording the panic, got %v", events)
+			}
+		})
+	}
+}
+
+func TestLoggingMiddleware_PanicAfterResponseStarted(t *testing.T) {
+	sampler, err := NewSampler(SamplingPolicy{Rate: 0})
+	if err != nil {
+		t.Fatalf("NewSampler() error = %v", err)
+	}
+
+	rr, events, _ := servePanicking(panickingHandler("boom", true), WithRecovery(RecoveryOptions{}), WithSampler(sampler))
+
+	// The response cannot be replaced once started, and panics are not sampled out
+	if rr.Code != http.StatusOK || rr.Body.String() != `{"items":[` {
+		t.Errorf("Expected the partial response to be left as is, got %d %s", rr.Code, rr.Body.String())
+	}
+	if len(events) != 1 || events[0] != "boom" {
+		t.Errorf("Expected one event recording the panic, got %v", events)
+	}
+}
+
+func TestPanicStack_MaxFrames(t *testing.T) {
+	mockClient := &mockPubSubClient{}
+	handler := LoggingMiddleware(mockClient, WithRecovery(RecoveryOptions{MaxStackFrames: 1}))(panickingHandler("boom", false))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/items", nil))
+
+	events := mockClient.getEvents()
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events))
+	}
+	if frames := strings.Count(events[0].PanicStack, "\n\t"); frames != 1 {
+		t.Errorf("Expected 1 frame, got %d:\n%s", frames, events[0].PanicStack)
+	}
+}
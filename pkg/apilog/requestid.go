@@ -1,20 +1,29 @@
-package middleware
+package apilog
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"net/http"
-
-	"api-pubsub-logger/internal/utils"
 )
 
+// GenerateRequestID generates a random request ID
+func GenerateRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
 // RequestIDMiddleware adds a unique request ID to each request
 func RequestIDMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		requestID := r.Header.Get("X-Request-ID")
 		if requestID == "" {
-			requestID = utils.GenerateRequestID()
+			requestID = GenerateRequestID()
 		}
 
-		ctx := utils.SetRequestID(r.Context(), requestID)
+		ctx := SetRequestID(r.Context(), requestID)
 		w.Header().Set("X-Request-ID", requestID)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
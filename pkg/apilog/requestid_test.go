@@ -1,11 +1,9 @@
-package middleware
+package apilog
 
 import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
-
-	"api-pubsub-logger/internal/utils"
 )
 
 func TestRequestIDMiddleware(t *testing.T) {
@@ -34,7 +32,7 @@ func TestRequestIDMiddleware(t *testing.T) {
 			// Create a test handler that checks if request ID is in context
 			var contextRequestID string
 			testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				contextRequestID = utils.GetRequestID(r.Context())
+				contextRequestID = GetRequestID(r.Context())
 				w.WriteHeader(http.StatusOK)
 			})
 
@@ -90,7 +88,7 @@ func TestRequestIDMiddleware(t *testing.T) {
 func TestRequestIDMiddleware_PropagatesContext(t *testing.T) {
 	var receivedRequestID string
 	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		receivedRequestID = utils.GetRequestID(r.Context())
+		receivedRequestID = GetRequestID(r.Context())
 		w.WriteHeader(http.StatusOK)
 	})
 
@@ -105,3 +103,22 @@ func TestRequestIDMiddleware_PropagatesContext(t *testing.T) {
 		t.Errorf("Expected request ID in context = %v, got %v", "test-123", receivedRequestID)
 	}
 }
+
+func TestGenerateRequestID(t *testing.T) {
+	// Test that request ID is generated
+	requestID := GenerateRequestID()
+	if requestID == "" {
+		t.Error("GenerateRequestID() returned empty string")
+	}
+
+	// Test that request ID has expected length (32 characters for 16 bytes in hex)
+	if len(requestID) != 32 {
+		t.Errorf("GenerateRequestID() length = %d, want 32", len(requestID))
+	}
+
+	// Test that multiple calls generate different IDs
+	requestID2 := GenerateRequestID()
+	if requestID == requestID2 {
+		t.Error("GenerateRequestID() generated same ID twice")
+	}
+}
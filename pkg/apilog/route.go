@@ -0,0 +1,65 @@
+package apilog
+
+import (
+	"net/http"
+	"strings"
+)
+
+// RouteInfo describes the route a request was matched to
+type RouteInfo struct {
+	// Name is the route name
+	Name string
+	// Template is the route path template, e.g. /v1/items/{id}
+	Template string
+	// Version is the API version of the route, e.g. v1
+	Version string
+}
+
+// RouteInfoExtractor returns the route of a request, or an empty RouteInfo when it has none
+type RouteInfoExtractor func(r *http.Request) RouteInfo
+
+// PatternRouteInfo extracts the route from the http.ServeMux pattern of a request
+// The pattern is only set once the ServeMux has routed the request, so a middleware
+// wrapping the ServeMux only sees it after calling the handler (see ServeMuxRouteInfo)
+func PatternRouteInfo(r *http.Request) RouteInfo {
+	return patternRouteInfo(r.Pattern)
+}
+
+// ServeMuxRouteInfo extracts the route from the pattern mux would route a request to,
+// it also works for a middleware wrapping mux before the request is routed
+func ServeMuxRouteInfo(mux *http.ServeMux) RouteInfoExtractor {
+	return func(r *http.Request) RouteInfo {
+		if r.Pattern != "" {
+			return patternRouteInfo(r.Pattern)
+		}
+		_, pattern := mux.Handler(r)
+		return patternRouteInfo(pattern)
+	}
+}
+
+// VersionFromTemplate returns the leading v segment of a path template, e.g. v1 for /v1/items
+func VersionFromTemplate(template string) string {
+	parts := strings.Split(strings.Trim(template, "/"), "/")
+	if len(parts) > 0 && strings.HasPrefix(parts[0], "v") {
+		return parts[0]
+	}
+	return ""
+}
+
+// patternRouteInfo names the route after the whole pattern ([METHOD ][HOST]/[PATH])
+// and uses its path as the template
+func patternRouteInfo(pattern string) RouteInfo {
+	if pattern == "" {
+		return RouteInfo{}
+	}
+
+	template := pattern
+	if i := strings.IndexByte(pattern, '/'); i >= 0 {
+		template = pattern[i:]
+	}
+	return RouteInfo{
+		Name:     pattern,
+		Template: template,
+		Version:  VersionFromTemplate(template),
+	}
+}
@@ -0,0 +1,63 @@
+package apilog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPatternRouteInfo(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		expected RouteInfo
+	}{
+		{name: "method and path", pattern: "GET /v1/items/{id}", expected: RouteInfo{Name: "GET /v1/items/{id}", Template: "/v1/items/{id}", Version: "v1"}},
+		{name: "path only", pattern: "/items", expected: RouteInfo{Name: "/items", Template: "/items"}},
+		{name: "host and path", pattern: "POST api.example.com/v2/users", expected: RouteInfo{Name: "POST api.example.com/v2/users", Template: "/v2/users", Version: "v2"}},
+		{name: "not routed", pattern: "", expected: RouteInfo{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Pattern = tt.pattern
+
+			if info := PatternRouteInfo(req); info != tt.expected {
+				t.Errorf("Expected route = %+v, got %+v", tt.expected, info)
+			}
+		})
+	}
+}
+
+func TestServeMuxRouteInfo(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/items/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	extract := ServeMuxRouteInfo(mux)
+
+	// The route is known before the ServeMux handles the request
+	info := extract(httptest.NewRequest("GET", "/v1/items/42", nil))
+	expected := RouteInfo{Name: "GET /v1/items/{id}", Template: "/v1/items/{id}", Version: "v1"}
+	if info != expected {
+		t.Errorf("Expected route = %+v, got %+v", expected, info)
+	}
+
+	if info := extract(httptest.NewRequest("GET", "/unknown", nil)); info != (RouteInfo{}) {
+		t.Errorf("Expected no route, got %+v", info)
+	}
+}
+
+func TestVersionFromTemplate(t *testing.T) {
+	tests := map[string]string{
+		"/v1/items":  "v1",
+		"/v2/users/": "v2",
+		"/items":     "",
+		"":           "",
+	}
+
+	for template, expected := range tests {
+		if version := VersionFromTemplate(template); version != expected {
+			t.Errorf("Expected version of %q = %v, got %v", template, expected, version)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package apilog
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// DefaultSkipRules are the skip rules used when none are configured, health checks are not logged
+var DefaultSkipRules = []Rule{
+	{Methods: []string{http.MethodGet}, Path: "/health"},
+}
+
+// Rule matches requests to skip or include in logging
+// A rule matches when every field that is set matches, an empty rule matches nothing
+type Rule struct {
+	// RouteName is the route name given by the RouteInfoExtractor
+	RouteName string `json:"route_name,omitempty"`
+	// PathTemplate is the route path template given by the RouteInfoExtractor, e.g. /v1/items/{id}
+	PathTemplate string `json:"path_template,omitempty"`
+	// Path is the exact request path
+	Path string `json:"path,omitempty"`
+	// PathGlob is a path.Match pattern on the request path, e.g. /internal/*
+	PathGlob string `json:"path_glob,omitempty"`
+	// PathRegex is a regular expression on the request path
+	PathRegex *regexp.Regexp `json:"path_regex,omitempty"`
+	// Methods is the set of HTTP methods, matched case-insensitively
+	Methods []string `json:"methods,omitempty"`
+	// Header is the name of a header that must be present
+	Header string `json:"header,omitempty"`
+	// UserAgent is a substring of the User-Agent header, e.g. kube-probe
+	UserAgent string `json:"user_agent,omitempty"`
+}
+
+// ParseRules parses rules from a JSON array, e.g. [{"route_name":"health"},{"user_agent":"kube-probe"}]
+func ParseRules(spec string) ([]Rule, error) {
+	var rules []Rule
+	if err := json.Unmarshal([]byte(spec), &rules); err != nil {
+		return nil, fmt.Errorf("invalid rules: %w", err)
+	}
+
+	for i, rule := range rules {
+		if rule.isEmpty() {
+			return nil, fmt.Errorf("rule %d matches nothing", i)
+		}
+		if rule.PathGlob != "" {
+			if _, err := path.Match(rule.PathGlob, ""); err != nil {
+				return nil, fmt.Errorf("rule %d: invalid path glob %q: %w", i, rule.PathGlob, err)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// Matches reports whether the request, matched to route, matches the rule
+func (rule Rule) Matches(r *http.Request, route RouteInfo) bool {
+	if rule.isEmpty() {
+		return false
+	}
+
+	if rule.RouteName != "" && route.Name != rule.RouteName {
+		return false
+	}
+	if rule.PathTemplate != "" && route.Template != rule.PathTemplate {
+		return false
+	}
+
+	if rule.Path != "" && r.URL.Path != rule.Path {
+		return false
+	}
+	if rule.PathGlob != "" {
+		if matched, _ := path.Match(rule.PathGlob, r.URL.Path); !matched {
+			return false
+		}
+	}
+	if rule.PathRegex != nil && !rule.PathRegex.MatchString(r.URL.Path) {
+		return false
+	}
+	if len(rule.Methods) > 0 && !containsFold(rule.Methods, r.Method) {
+		return false
+	}
+	if rule.Header != "" && len(r.Header.Values(rule.Header)) == 0 {
+		return false
+	}
+	if rule.UserAgent != "" && !strings.Contains(r.UserAgent(), rule.UserAgent) {
+		return false
+	}
+	return true
+}
+
+func (rule Rule) isEmpty() bool {
+	return rule.RouteName == "" && rule.PathTemplate == "" && rule.Path == "" && rule.PathGlob == "" &&
+		rule.PathRegex == nil && len(rule.Methods) == 0 && rule.Header == "" && rule.UserAgent == ""
+}
+
+// matchesAny reports whether the request matches one of the rules
+func matchesAny(rules []Rule, r *http.Request, route RouteInfo) bool {
+	for _, rule := range rules {
+		if rule.Matches(r, route) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
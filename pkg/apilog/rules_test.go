@@ -0,0 +1,113 @@
+package apilog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestRule_Matches(t *testing.T) {
+	tests := []struct {
+		name      string
+		rule      Rule
+		method    string
+		target    string
+		headers   map[string]string
+		wantMatch bool
+	}{
+		{name: "route name", rule: Rule{RouteName: "get_item"}, method: "GET", target: "/v1/items/42", wantMatch: true},
+		{name: "other route name", rule: Rule{RouteName: "list_items"}, method: "GET", target: "/v1/items/42"},
+		{name: "path template", rule: Rule{PathTemplate: "/v1/items/{id}"}, method: "GET", target: "/v1/items/42", wantMatch: true},
+		{name: "exact path", rule: Rule{Path: "/v1/items/42"}, method: "GET", target: "/v1/items/42", wantMatch: true},
+		{name: "glob", rule: Rule{PathGlob: "/v1/items/*"}, method: "GET", target: "/v1/items/42", wantMatch: true},
+		{name: "glob does not cross segments", rule: Rule{PathGlob: "/v1/*"}, method: "GET", target: "/v1/items/42"},
+		{name: "regex", rule: Rule{PathRegex: regexp.MustCompile(`^/v1/items/\d+$`)}, method: "GET", target: "/v1/items/42", wantMatch: true},
+		{name: "methods", rule: Rule{Methods: []string{"get", "head"}}, method: "GET", target: "/v1/items/42", wantMatch: true},
+		{name: "other methods", rule: Rule{Methods: []string{"POST"}}, method: "GET", target: "/v1/items/42"},
+		{name: "header present", rule: Rule{Header: "X-Synthetic"}, method: "GET", target: "/v1/items/42", headers: map[string]string{"X-Synthetic": "1"}, wantMatch: true},
+		{name: "header missing", rule: Rule{Header: "X-Synthetic"}, method: "GET", target: "/v1/items/42"},
+		{name: "user agent", rule: Rule{UserAgent: "kube-probe"}, method: "GET", target: "/v1/items/42", headers: map[string]string{"User-Agent": "kube-probe/1.29"}, wantMatch: true},
+		{name: "all fields must match", rule: Rule{RouteName: "get_item", Methods: []string{"POST"}}, method: "GET", target: "/v1/items/42"},
+		{name: "empty rule", rule: Rule{}, method: "GET", target: "/v1/items/42"},
+	}
+
+	route := RouteInfo{Name: "get_item", Template: "/v1/items/{id}", Version: "v1"}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			if matched := tt.rule.Matches(req, route); matched != tt.wantMatch {
+				t.Errorf("Expected match = %v, got %v", tt.wantMatch, matched)
+			}
+		})
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules(`[{"route_name":"health"},{"user_agent":"kube-probe"},{"path_regex":"^/internal/","methods":["GET"]}]`)
+	if err != nil {
+		t.Fatalf("ParseRules() error = %v", err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("Expected 3 rules, got %d", len(rules))
+	}
+	if rules[2].PathRegex == nil || !rules[2].PathRegex.MatchString("/internal/metrics") {
+		t.Errorf("Expected compiled path regex, got %v", rules[2].PathRegex)
+	}
+
+	invalid := []string{
+		`{"route_name":"health"}`,
+		`[{}]`,
+		`[{"path_regex":"("}]`,
+		`[{"path_glob":"["}]`,
+	}
+	for _, spec := range invalid {
+		if _, err := ParseRules(spec); err == nil {
+			t.Errorf("Expected error for %s", spec)
+		}
+	}
+}
+
+func TestLoggingMiddleware_Rules(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []Option
+		target     string
+		userAgent  string
+		wantLogged bool
+	}{
+		{name: "default skips health", target: "/health"},
+		{name: "default logs other routes", target: "/v1/items", wantLogged: true},
+		{name: "custom skip rules replace the default", opts: []Option{WithSkipRules(Rule{UserAgent: "kube-probe"})}, target: "/health", wantLogged: true},
+		{name: "custom skip rule", opts: []Option{WithSkipRules(Rule{UserAgent: "kube-probe"})}, target: "/v1/items", userAgent: "kube-probe/1.29"},
+		{name: "include rule", opts: []Option{WithIncludeRules(Rule{RouteName: "GET /v1/items"})}, target: "/v1/items", wantLogged: true},
+		{name: "include rule on path template", opts: []Option{WithIncludeRules(Rule{PathTemplate: "/v1/items"})}, target: "/v1/items", wantLogged: true},
+		{name: "not included", opts: []Option{WithIncludeRules(Rule{RouteName: "POST /v1/items"})}, target: "/v1/items"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := &mockPubSubClient{}
+
+			mux := http.NewServeMux()
+			ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+			mux.HandleFunc("GET /health", ok)
+			mux.HandleFunc("GET /v1/items", ok)
+			opts := append([]Option{WithRouteInfo(ServeMuxRouteInfo(mux))}, tt.opts...)
+			handler := LoggingMiddleware(mockClient, opts...)(mux)
+
+			req := httptest.NewRequest("GET", tt.target, nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if logged := len(mockClient.getEvents()) == 1; logged != tt.wantLogged {
+				t.Errorf("Expected logged = %v, got %v", tt.wantLogged, logged)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package apilog
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"api-pubsub-logger/pkg/logger"
+)
+
+// SamplingPolicy controls which API log events are kept
+type SamplingPolicy struct {
+	// Rate is the fraction (0 to 1) of events kept
+	Rate float64
+	// RouteRates overrides Rate by route name
+	RouteRates map[string]float64
+	// KeepErrors keeps every event with a non-2xx response code
+	KeepErrors bool
+	// SlowThreshold keeps every request slower than this, disabled when zero
+	SlowThreshold time.Duration
+	// KeepUsers keeps every event of these user IDs
+	KeepUsers []string
+	// Deterministic samples on a hash of the request ID instead of randomly,
+	// so services sampling at the same rate keep the same requests
+	Deterministic bool
+}
+
+// Sampler decides whether API log events are kept according to a SamplingPolicy
+type Sampler struct {
+	policy    SamplingPolicy
+	keepUsers map[string]struct{}
+	random    func() float64
+}
+
+// NewSampler returns a sampler for the given policy
+func NewSampler(policy SamplingPolicy) (*Sampler, error) {
+	if err := validateRate("sample rate", policy.Rate); err != nil {
+		return nil, err
+	}
+	for route, rate := range policy.RouteRates {
+		if err := validateRate(fmt.Sprintf("sample rate of route %q", route), rate); err != nil {
+			return nil, err
+		}
+	}
+
+	keepUsers := make(map[string]struct{}, len(policy.KeepUsers))
+	for _, userID := range policy.KeepUsers {
+		keepUsers[userID] = struct{}{}
+	}
+
+	return &Sampler{
+		policy:    policy,
+		keepUsers: keepUsers,
+		random:    rand.Float64,
+	}, nil
+}
+
+// Sample reports whether an event is kept and the rate it was sampled at
+// Events kept by a keep rule are sampled at rate 1, so 1/rate re-weights counts in every case
+func (s *Sampler) Sample(event logger.APILogEvent) (bool, float64) {
+	if s.alwaysKeep(event) {
+		return true, 1
+	}
+
+	rate := s.policy.Rate
+	if routeRate, ok := s.policy.RouteRates[event.Name]; ok {
+		rate = routeRate
+	}
+	if rate >= 1 {
+		return true, 1
+	}
+	if rate <= 0 {
+		return false, 0
+	}
+
+	return s.position(event) < rate, rate
+}
+
+// alwaysKeep reports whether a keep rule matches the event
+func (s *Sampler) alwaysKeep(event logger.APILogEvent) bool {
+	if s.policy.KeepErrors && (event.ResponseCode < 200 || event.ResponseCode > 299) {
+		return true
+	}
+	if s.policy.SlowThreshold > 0 && event.Duration >= s.policy.SlowThreshold.Seconds() {
+		return true
+	}
+	if event.UserID.Valid {
+		if _, ok := s.keepUsers[event.UserID.String]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// position returns where the event falls in [0, 1), it is kept when below the rate
+// In deterministic mode it is the first 8 bytes of the SHA-256 of the request ID (big endian)
+// divided by 2^64, which other services can easily reproduce
+func (s *Sampler) position(event logger.APILogEvent) float64 {
+	if !s.policy.Deterministic || event.RequestID.String == "" {
+		return s.random()
+	}
+
+	sum := sha256.Sum256([]byte(event.RequestID.String))
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11) / (1 << 53)
+}
+
+func validateRate(name string, rate float64) error {
+	if rate < 0 || rate > 1 {
+		return fmt.Errorf("%s must be between 0 and 1, got %v", name, rate)
+	}
+	return nil
+}
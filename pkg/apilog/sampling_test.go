@@ -0,0 +1,139 @@
+package apilog
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"api-pubsub-logger/pkg/logger"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestSampler_Sample(t *testing.T) {
+	policy := SamplingPolicy{
+		Rate:          0.1,
+		RouteRates:    map[string]float64{"list_items": 0, "create_item": 1},
+		KeepErrors:    true,
+		SlowThreshold: time.Second,
+		KeepUsers:     []string{"user-vip"},
+	}
+
+	tests := []struct {
+		name     string
+		event    logger.APILogEvent
+		random   float64
+		wantKeep bool
+		wantRate float64
+	}{
+		{name: "kept by global rate", event: logger.APILogEvent{Name: "get_item", ResponseCode: 200}, random: 0.05, wantKeep: true, wantRate: 0.1},
+		{name: "dropped by global rate", event: logger.APILogEvent{Name: "get_item", ResponseCode: 200}, random: 0.5, wantRate: 0.1},
+		{name: "dropped by route rate", event: logger.APILogEvent{Name: "list_items", ResponseCode: 200}, random: 0},
+		{name: "kept by route rate", event: logger.APILogEvent{Name: "create_item", ResponseCode: 201}, random: 0.99, wantKeep: true, wantRate: 1},
+		{name: "errors are kept", event: logger.APILogEvent{Name: "list_items", ResponseCode: 500}, random: 0.99, wantKeep: true, wantRate: 1},
+		{name: "slow requests are kept", event: logger.APILogEvent{Name: "list_items", ResponseCode: 200, Duration: 1.5}, random: 0.99, wantKeep: true, wantRate: 1},
+		{
+			name:     "listed users are kept",
+			event:    logger.APILogEvent{Name: "list_items", ResponseCode: 200, UserID: null.StringFrom("user-vip")},
+			random:   0.99,
+			wantKeep: true,
+			wantRate: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sampler, err := NewSampler(policy)
+			if err != nil {
+				t.Fatalf("NewSampler() error = %v", err)
+			}
+			sampler.random = func() float64 { return tt.random }
+
+			keep, rate := sampler.Sample(tt.event)
+			if keep != tt.wantKeep || rate != tt.wantRate {
+				t.Errorf("Expected keep = %v at rate %v, got %v at rate %v", tt.wantKeep, tt.wantRate, keep, rate)
+			}
+		})
+	}
+}
+
+func TestSampler_Deterministic(t *testing.T) {
+	// Two samplers standing for two services
+	first, err := NewSampler(SamplingPolicy{Rate: 0.5, Deterministic: true})
+	if err != nil {
+		t.Fatalf("NewSampler() error = %v", err)
+	}
+	second, err := NewSampler(SamplingPolicy{Rate: 0.5, Deterministic: true})
+	if err != nil {
+		t.Fatalf("NewSampler() error = %v", err)
+	}
+
+	kept := 0
+	for i := 0; i < 1000; i++ {
+		event := logger.APILogEvent{RequestID: null.StringFrom(fmt.Sprintf("req-%d", i)), ResponseCode: 200}
+		firstKeep, _ := first.Sample(event)
+		secondKeep, _ := second.Sample(event)
+		if firstKeep != secondKeep {
+			t.Fatalf("Expected consistent decision for %s", event.RequestID.String)
+		}
+		if firstKeep {
+			kept++
+		}
+	}
+
+	if kept < 400 || kept > 600 {
+		t.Errorf("Expected about half of the events to be kept, got %d/1000", kept)
+	}
+}
+
+func TestNewSampler_RejectsInvalidRates(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy SamplingPolicy
+	}{
+		{name: "negative rate", policy: SamplingPolicy{Rate: -0.1}},
+		{name: "rate above 1", policy: SamplingPolicy{Rate: 2}},
+		{name: "invalid route rate", policy: SamplingPolicy{Rate: 1, RouteRates: map[string]float64{"list_items": 1.5}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSampler(tt.policy); err == nil {
+				t.Error("Expected error for invalid rate")
+			}
+		})
+	}
+}
+
+func TestLoggingMiddleware_Sampling(t *testing.T) {
+	mockClient := &mockPubSubClient{}
+
+	sampler, err := NewSampler(SamplingPolicy{Rate: 0.25, KeepErrors: true})
+	if err != nil {
+		t.Fatalf("NewSampler() error = %v", err)
+	}
+	sampler.random = func() float64 { return 0.5 }
+
+	statusCode := http.StatusOK
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statusCode)
+	})
+	handler := LoggingMiddleware(mockClient, WithServiceName("test-service"), WithSampler(sampler))(testHandler)
+
+	for _, code := range []int{http.StatusOK, http.StatusBadGateway} {
+		statusCode = code
+		req := httptest.NewRequest("GET", "/v1/items", nil)
+		req = req.WithContext(SetRequestID(req.Context(), "req-123"))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	events := mockClient.getEvents()
+	if len(events) != 1 {
+		t.Fatalf("Expected only the error to be published, got %d events", len(events))
+	}
+	if events[0].ResponseCode != http.StatusBadGateway || events[0].SampleRate != 1 {
+		t.Errorf("Expected kept error with sample rate 1, got code %d rate %v", events[0].ResponseCode, events[0].SampleRate)
+	}
+}
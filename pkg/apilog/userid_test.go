@@ -1,11 +1,9 @@
-package middleware
+package apilog
 
 import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
-
-	"api-pubsub-logger/internal/utils"
 )
 
 func TestUserIDMiddleware(t *testing.T) {
@@ -30,7 +28,7 @@ func TestUserIDMiddleware(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			var contextUserID string
 			testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				contextUserID = utils.GetUserID(r.Context())
+				contextUserID = GetUserID(r.Context())
 				w.WriteHeader(http.StatusOK)
 			})
 
@@ -58,7 +56,7 @@ func TestUserIDMiddleware_PropagatesContext(t *testing.T) {
 	var receivedUserID string
 
 	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		receivedUserID = utils.GetUserID(r.Context())
+		receivedUserID = GetUserID(r.Context())
 		w.WriteHeader(http.StatusOK)
 	})
 
@@ -79,7 +77,7 @@ func TestUserIDMiddleware_WithMultipleHeaders(t *testing.T) {
 	var receivedUserID string
 
 	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		receivedUserID = utils.GetUserID(r.Context())
+		receivedUserID = GetUserID(r.Context())
 		w.WriteHeader(http.StatusOK)
 	})
 
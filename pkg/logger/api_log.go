@@ -20,4 +20,45 @@ type APILogEvent struct {
 	Version      string      `json:"version"`
 	Name         string      `json:"name"`
 	CreatedAt    time.Time   `json:"created_at"`
+	// Truncated is set when a body was cut to fit the size limit, the sizes are those before truncation
+	// and the captured sizes those of the part kept (before masking) when it was cut while being captured
+	Truncated                bool `json:"truncated,omitempty"`
+	RequestBodySize          int  `json:"request_body_size,omitempty"`
+	ResponseBodySize         int  `json:"response_body_size,omitempty"`
+	RequestBodyCapturedSize  int  `json:"request_body_captured_size,omitempty"`
+	ResponseBodyCapturedSize int  `json:"response_body_captured_size,omitempty"`
+	// EncryptionKeyID is the ID of the data key the bodies are encrypted with, they are in the clear when empty
+	EncryptionKeyID string `json:"encryption_key_id,omitempty"`
+	// SampleRate is the rate the event was sampled at when sampling is enabled, each event stands for 1/SampleRate requests
+	SampleRate float64 `json:"sample_rate,omitempty"`
+	// RequestHeaders and ResponseHeaders are the captured headers by canonical name, multiple values are comma-separated
+	RequestHeaders  map[string]string `json:"request_headers,omitempty"`
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+	// ResponseMode is set when the response was streamed or hijacked, its body is not logged
+	// and ResponseBodySize counts the bytes written before the connection was handed over
+	ResponseMode string `json:"response_mode,omitempty"`
+	// Panic is the value the handler panicked with and PanicStack the stack trace of the panic
+	Panic      string `json:"panic,omitempty"`
+	PanicStack string `json:"panic_stack,omitempty"`
+	// ClientIP is the address of the client, forwarded by trusted proxies or else the peer address
+	ClientIP  string `json:"client_ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	Referer   string `json:"referer,omitempty"`
+	Protocol  string `json:"protocol,omitempty"`
+	Host      string `json:"host,omitempty"`
+	// TLSVersion is the TLS version of the connection (e.g. "TLS 1.3"), empty for plain HTTP
+	TLSVersion string `json:"tls_version,omitempty"`
+	// RequestBytes and ResponseBytes are the sizes of the whole request and response bodies,
+	// the request size is the announced Content-Length when the body was not all read
+	RequestBytes  int64 `json:"request_bytes,omitempty"`
+	ResponseBytes int64 `json:"response_bytes,omitempty"`
+	// EventID is a unique ID assigned when the event is captured, kept across retries and spool replays
+	EventID string `json:"event_id,omitempty"`
 }
+
+const (
+	// ResponseModeStreamed marks responses flushed by the handler while being written (e.g. server-sent events)
+	ResponseModeStreamed = "streamed"
+	// ResponseModeHijacked marks responses whose connection was taken over by the handler (e.g. WebSockets)
+	ResponseModeHijacked = "hijacked"
+)
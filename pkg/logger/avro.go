@@ -0,0 +1,249 @@
+package logger
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+// AvroSchema is the Avro definition of APILogEvent (see api_log.avsc)
+//
+//go:embed api_log.avsc
+var AvroSchema string
+
+// errAvroShortBuffer is returned when Avro data ends in the middle of a value
+var errAvroShortBuffer = errors.New("avro: unexpected end of data")
+
+// AvroEncoder encodes events in the Avro binary encoding of api_log.avsc
+type AvroEncoder struct{}
+
+// ContentType returns the Avro content type
+func (AvroEncoder) ContentType() string {
+	return ContentTypeAvro
+}
+
+// Encode encodes an event as Avro, fields are written in schema order
+func (AvroEncoder) Encode(event APILogEvent) ([]byte, error) {
+	var b []byte
+
+	b = appendAvroNullString(b, event.RequestID)
+	b = appendAvroString(b, event.Service)
+	b = appendAvroString(b, event.URL)
+	b = appendAvroString(b, event.Method)
+	b = binary.AppendVarint(b, int64(event.ResponseCode))
+	b = appendAvroNullString(b, event.ResponseBody)
+	b = appendAvroNullString(b, event.RequestBody)
+	b = appendAvroNullString(b, event.UserID)
+	b = binary.LittleEndian.AppendUint64(b, math.Float64bits(event.Duration))
+	b = appendAvroString(b, event.Version)
+	b = appendAvroString(b, event.Name)
+	b = binary.AppendVarint(b, event.CreatedAt.UnixMicro())
+	if event.Truncated {
+		b = append(b, 1)
+	} else {
+		b = append(b, 0)
+	}
+	b = binary.AppendVarint(b, int64(event.RequestBodySize))
+	b = binary.AppendVarint(b, int64(event.ResponseBodySize))
+	b = appendAvroString(b, event.EncryptionKeyID)
+	b = binary.LittleEndian.AppendUint64(b, math.Float64bits(event.SampleRate))
+	b = appendAvroMap(b, event.RequestHeaders)
+	b = appendAvroMap(b, event.ResponseHeaders)
+	b = appendAvroString(b, event.ResponseMode)
+	b = binary.AppendVarint(b, int64(event.RequestBodyCapturedSize))
+	b = binary.AppendVarint(b, int64(event.ResponseBodyCapturedSize))
+	b = appendAvroString(b, event.Panic)
+	b = appendAvroString(b, event.PanicStack)
+	b = appendAvroString(b, event.ClientIP)
+	b = appendAvroString(b, event.UserAgent)
+	b = appendAvroString(b, event.Referer)
+	b = appendAvroString(b, event.Protocol)
+	b = appendAvroString(b, event.Host)
+	b = appendAvroString(b, event.TLSVersion)
+	b = binary.AppendVarint(b, event.RequestBytes)
+	b = binary.AppendVarint(b, event.ResponseBytes)
+	b = appendAvroString(b, event.EventID)
+
+	return b, nil
+}
+
+// Decode decodes an Avro event
+func (AvroEncoder) Decode(data []byte) (APILogEvent, error) {
+	r := &avroReader{data: data}
+
+	event := APILogEvent{
+		RequestID:    r.nullString(),
+		Service:      r.string(),
+		URL:          r.string(),
+		Method:       r.string(),
+		ResponseCode: int(r.long()),
+		ResponseBody: r.nullString(),
+		RequestBody:  r.nullString(),
+		UserID:       r.nullString(),
+		Duration:     r.double(),
+		Version:      r.string(),
+		Name:         r.string(),
+		CreatedAt:    time.UnixMicro(r.long()).UTC(),
+	}
+	event.Truncated = r.boolean()
+	event.RequestBodySize = int(r.long())
+	event.ResponseBodySize = int(r.long())
+	event.EncryptionKeyID = r.string()
+	event.SampleRate = r.double()
+	event.RequestHeaders = r.stringMap()
+	event.ResponseHeaders = r.stringMap()
+	event.ResponseMode = r.string()
+	event.RequestBodyCapturedSize = int(r.long())
+	event.ResponseBodyCapturedSize = int(r.long())
+	event.Panic = r.string()
+	event.PanicStack = r.string()
+	event.ClientIP = r.string()
+	event.UserAgent = r.string()
+	event.Referer = r.string()
+	event.Protocol = r.string()
+	event.Host = r.string()
+	event.TLSVersion = r.string()
+	event.RequestBytes = r.long()
+	event.ResponseBytes = r.long()
+	event.EventID = r.string()
+
+	if r.err != nil {
+		return APILogEvent{}, r.err
+	}
+	return event, nil
+}
+
+func appendAvroString(b []byte, v string) []byte {
+	b = binary.AppendVarint(b, int64(len(v)))
+	return append(b, v...)
+}
+
+// appendAvroNullString appends a ["null", "string"] union
+func appendAvroNullString(b []byte, v null.String) []byte {
+	if !v.Valid {
+		return binary.AppendVarint(b, 0)
+	}
+	b = binary.AppendVarint(b, 1)
+	return appendAvroString(b, v.String)
+}
+
+// appendAvroMap appends a string map as a single block in key order, an empty map is just the end marker
+func appendAvroMap(b []byte, m map[string]string) []byte {
+	if len(m) == 0 {
+		return binary.AppendVarint(b, 0)
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	b = binary.AppendVarint(b, int64(len(keys)))
+	for _, k := range keys {
+		b = appendAvroString(b, k)
+		b = appendAvroString(b, m[k])
+	}
+	return binary.AppendVarint(b, 0)
+}
+
+// avroReader reads Avro binary values, the first error stops all further reads
+type avroReader struct {
+	data []byte
+	err  error
+}
+
+func (r *avroReader) long() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.err = errAvroShortBuffer
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *avroReader) string() string {
+	size := r.long()
+	if r.err != nil {
+		return ""
+	}
+	if size < 0 || size > int64(len(r.data)) {
+		r.err = errAvroShortBuffer
+		return ""
+	}
+	v := string(r.data[:size])
+	r.data = r.data[size:]
+	return v
+}
+
+func (r *avroReader) nullString() null.String {
+	switch index := r.long(); {
+	case r.err != nil:
+		return null.String{}
+	case index == 0:
+		return null.String{}
+	case index == 1:
+		return null.StringFrom(r.string())
+	default:
+		r.err = errors.New("avro: invalid union index")
+		return null.String{}
+	}
+}
+
+func (r *avroReader) double() float64 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.data) < 8 {
+		r.err = errAvroShortBuffer
+		return 0
+	}
+	v := math.Float64frombits(binary.LittleEndian.Uint64(r.data))
+	r.data = r.data[8:]
+	return v
+}
+
+func (r *avroReader) boolean() bool {
+	if r.err != nil {
+		return false
+	}
+	if len(r.data) < 1 {
+		r.err = errAvroShortBuffer
+		return false
+	}
+	v := r.data[0] != 0
+	r.data = r.data[1:]
+	return v
+}
+
+// stringMap reads a string map, it is nil when empty
+func (r *avroReader) stringMap() map[string]string {
+	var m map[string]string
+	for {
+		count := r.long()
+		if r.err != nil || count == 0 {
+			return m
+		}
+		if count < 0 {
+			// A negative count is followed by the block size in bytes
+			count = -count
+			r.long()
+		}
+		if m == nil {
+			m = make(map[string]string)
+		}
+		for i := int64(0); i < count && r.err == nil; i++ {
+			key := r.string()
+			m[key] = r.string()
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package logger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// CloudEvents modes of the Pub/Sub protocol binding
+const (
+	// CloudEventsStructured wraps the event in a JSON CloudEvents envelope
+	CloudEventsStructured = "structured"
+	// CloudEventsBinary keeps the event as message data and puts the context in ce-* attributes
+	CloudEventsBinary = "binary"
+)
+
+const (
+	// CloudEventsSpecVersion is the CloudEvents version of published envelopes
+	CloudEventsSpecVersion = "1.0"
+	// CloudEventType is the CloudEvents type of API log events
+	CloudEventType = "apilogger.APILogEvent"
+	// ContentTypeCloudEvents is the content type of structured mode envelopes
+	ContentTypeCloudEvents = "application/cloudevents+json"
+	// cloudEventsAttributePrefix prefixes the context attributes in binary mode
+	cloudEventsAttributePrefix = "ce-"
+)
+
+// CloudEvent is a structured mode CloudEvents envelope
+// JSON data is inlined in Data, other encodings are base64 encoded in DataBase64
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	Type            string          `json:"type"`
+	Source          string          `json:"source"`
+	ID              string          `json:"id"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	DataBase64      []byte          `json:"data_base64,omitempty"`
+}
+
+// ValidateCloudEventsMode returns an error if mode is not empty, structured or binary
+func ValidateCloudEventsMode(mode string) error {
+	switch mode {
+	case "", CloudEventsStructured, CloudEventsBinary:
+		return nil
+	default:
+		return fmt.Errorf("unknown CloudEvents mode %q", mode)
+	}
+}
+
+// NewCloudEvent returns the envelope of an event already encoded by encoder
+// The request ID is used as event ID, or else the ID assigned when the event was captured
+func NewCloudEvent(event APILogEvent, data []byte, contentType string) CloudEvent {
+	ce := CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		Type:            CloudEventType,
+		Source:          event.Service,
+		ID:              cloudEventID(event),
+		Time:            event.CreatedAt,
+		DataContentType: contentType,
+	}
+	if contentType == ContentTypeJSON {
+		ce.Data = data
+	} else {
+		ce.DataBase64 = data
+	}
+	return ce
+}
+
+// CloudEventAttributes returns the binary mode ce-* attributes of an event
+func CloudEventAttributes(event APILogEvent) map[string]string {
+	return map[string]string{
+		cloudEventsAttributePrefix + "specversion": CloudEventsSpecVersion,
+		cloudEventsAttributePrefix + "type":        CloudEventType,
+		cloudEventsAttributePrefix + "source":      event.Service,
+		cloudEventsAttributePrefix + "id":          cloudEventID(event),
+		cloudEventsAttributePrefix + "time":        event.CreatedAt.Format(time.RFC3339Nano),
+	}
+}
+
+// cloudEventID returns the same ID every time an event is encoded, so that consumers can
+// deduplicate retries and spool replays
+func cloudEventID(event APILogEvent) string {
+	if event.RequestID.String != "" {
+		return event.RequestID.String
+	}
+	if event.EventID != "" {
+		return event.EventID
+	}
+
+	// Events not captured by the middleware are identified by their content
+	data, _ := json.Marshal(event)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// decodeCloudEvent unwraps a structured mode envelope and decodes its data
+func decodeCloudEvent(data []byte) (APILogEvent, error) {
+	var ce CloudEvent
+	if err := json.Unmarshal(data, &ce); err != nil {
+		return APILogEvent{}, err
+	}
+	if ce.SpecVersion != CloudEventsSpecVersion {
+		return APILogEvent{}, fmt.Errorf("unsupported CloudEvents version %q", ce.SpecVersion)
+	}
+
+	encoder, err := encoderForContentType(ce.DataContentType)
+	if err != nil {
+		return APILogEvent{}, err
+	}
+	if ce.DataBase64 != nil {
+		return encoder.Decode(ce.DataBase64)
+	}
+	return encoder.Decode(ce.Data)
+}
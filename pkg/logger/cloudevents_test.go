@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNewCloudEvent(t *testing.T) {
+	event := newTestEvent()
+
+	tests := []struct {
+		name        string
+		encoder     Encoder
+		wantInlined bool
+	}{
+		{name: "json data is inlined", encoder: JSONEncoder{}, wantInlined: true},
+		{name: "binary data is base64 encoded", encoder: ProtobufEncoder{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.encoder.Encode(event)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+
+			ce := NewCloudEvent(event, data, tt.encoder.ContentType())
+			if ce.SpecVersion != "1.0" || ce.Type != CloudEventType || ce.Source != "test-service" || ce.ID != "req-1" {
+				t.Errorf("Unexpected context attributes: %+v", ce)
+			}
+			if !ce.Time.Equal(event.CreatedAt) {
+				t.Errorf("Expected time = %v, got %v", event.CreatedAt, ce.Time)
+			}
+			if ce.DataContentType != tt.encoder.ContentType() {
+				t.Errorf("Expected datacontenttype = %s, got %s", tt.encoder.ContentType(), ce.DataContentType)
+			}
+			if (ce.Data != nil) != tt.wantInlined || (ce.DataBase64 != nil) == tt.wantInlined {
+				t.Errorf("Expected inlined data = %v, got data=%s data_base64=%x", tt.wantInlined, ce.Data, ce.DataBase64)
+			}
+
+			envelope, err := json.Marshal(ce)
+			if err != nil {
+				t.Fatalf("Failed to marshal envelope: %v", err)
+			}
+			got, err := DecodeMessage(envelope, map[string]string{ContentTypeAttribute: ContentTypeCloudEvents})
+			if err != nil {
+				t.Fatalf("DecodeMessage() error = %v", err)
+			}
+			if got.RequestID.String != "req-1" || got.ResponseBodySize != 2048 {
+				t.Errorf("Unexpected decoded event: %+v", got)
+			}
+		})
+	}
+}
+
+func TestNewCloudEvent_KeepsIDWithoutRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    APILogEvent
+		expected string
+	}{
+		{name: "event ID", event: APILogEvent{Service: "test-service", EventID: "evt-1"}, expected: "evt-1"},
+		{name: "event not captured by the middleware", event: APILogEvent{Service: "test-service"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Retries and spool replays encode the event again, its ID must not change
+			first := NewCloudEvent(tt.event, []byte("{}"), ContentTypeJSON)
+			second := NewCloudEvent(tt.event, []byte("{}"), ContentTypeJSON)
+			attrs := CloudEventAttributes(tt.event)
+			if first.ID == "" || first.ID != second.ID || attrs["ce-id"] != first.ID {
+				t.Errorf("Expected the same ID on every encode, got %q, %q and %q", first.ID, second.ID, attrs["ce-id"])
+			}
+			if tt.expected != "" && first.ID != tt.expected {
+				t.Errorf("Expected ID = %q, got %q", tt.expected, first.ID)
+			}
+		})
+	}
+}
+
+func TestCloudEventAttributes(t *testing.T) {
+	event := newTestEvent()
+	attrs := CloudEventAttributes(event)
+
+	expected := map[string]string{
+		"ce-specversion": "1.0",
+		"ce-type":        CloudEventType,
+		"ce-source":      "test-service",
+		"ce-id":          "req-1",
+		"ce-time":        event.CreatedAt.Format(time.RFC3339Nano),
+	}
+	for name, value := range expected {
+		if attrs[name] != value {
+			t.Errorf("Expected %s = %q, got %q", name, value, attrs[name])
+		}
+	}
+}
+
+func TestDecodeMessage_RejectsUnknownSpecVersion(t *testing.T) {
+	data := []byte(`{"specversion":"0.3","data":{}}`)
+	if _, err := DecodeMessage(data, map[string]string{ContentTypeAttribute: ContentTypeCloudEvents}); err == nil {
+		t.Error("Expected error for unsupported CloudEvents version")
+	}
+}
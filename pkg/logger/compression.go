@@ -0,0 +1,76 @@
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ContentEncodingAttribute is the message attribute naming the compression applied to the data
+const ContentEncodingAttribute = "content-encoding"
+
+// Supported content encodings, an empty encoding means the data is not compressed
+const (
+	EncodingGzip = "gzip"
+	EncodingZstd = "zstd"
+)
+
+// zstd encoders and decoders are safe for concurrent use through EncodeAll and DecodeAll
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// ValidateEncoding returns an error if encoding is not supported
+func ValidateEncoding(encoding string) error {
+	switch encoding {
+	case "", EncodingGzip, EncodingZstd:
+		return nil
+	default:
+		return fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
+
+// Compress compresses data with the given content encoding
+func Compress(data []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return data, nil
+	case EncodingGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case EncodingZstd:
+		return zstdEncoder.EncodeAll(data, nil), nil
+	default:
+		return nil, ValidateEncoding(encoding)
+	}
+}
+
+// Decompress reverses Compress
+func Decompress(data []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return data, nil
+	case EncodingGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	case EncodingZstd:
+		return zstdDecoder.DecodeAll(data, nil)
+	default:
+		return nil, ValidateEncoding(encoding)
+	}
+}
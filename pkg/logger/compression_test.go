@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompressDecompress(t *testing.T) {
+	data := bytes.Repeat([]byte(`{"id":"1","name":"item"}`), 100)
+
+	for _, encoding := range []string{"", EncodingGzip, EncodingZstd} {
+		t.Run(encoding, func(t *testing.T) {
+			compressed, err := Compress(data, encoding)
+			if err != nil {
+				t.Fatalf("Compress() error = %v", err)
+			}
+			if encoding != "" && len(compressed) >= len(data) {
+				t.Errorf("Expected compressed data to be smaller, got %d bytes for %d", len(compressed), len(data))
+			}
+
+			decompressed, err := Decompress(compressed, encoding)
+			if err != nil {
+				t.Fatalf("Decompress() error = %v", err)
+			}
+			if !bytes.Equal(decompressed, data) {
+				t.Error("Expected decompressed data to match the original")
+			}
+		})
+	}
+}
+
+func TestCompress_RejectsUnknownEncoding(t *testing.T) {
+	if _, err := Compress([]byte("data"), "br"); err == nil {
+		t.Error("Expected error for unknown encoding")
+	}
+	if err := ValidateEncoding("br"); err == nil {
+		t.Error("Expected ValidateEncoding error for unknown encoding")
+	}
+}
@@ -0,0 +1,101 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ContentTypeAttribute is the message attribute naming the encoding of the data, JSON is assumed when missing
+const ContentTypeAttribute = "content-type"
+
+// Content types of the supported encodings
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/protobuf"
+	ContentTypeAvro     = "avro/binary"
+)
+
+// Encoder converts API log events to and from a wire format
+type Encoder interface {
+	// ContentType identifies the wire format
+	ContentType() string
+	Encode(event APILogEvent) ([]byte, error)
+	Decode(data []byte) (APILogEvent, error)
+}
+
+// NewEncoder returns the encoder for an encoding name: json, protobuf or avro
+func NewEncoder(name string) (Encoder, error) {
+	switch name {
+	case "", "json":
+		return JSONEncoder{}, nil
+	case "protobuf":
+		return ProtobufEncoder{}, nil
+	case "avro":
+		return AvroEncoder{}, nil
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", name)
+	}
+}
+
+// encoderForContentType returns the encoder for a content type attribute
+func encoderForContentType(contentType string) (Encoder, error) {
+	switch contentType {
+	case "", ContentTypeJSON:
+		return JSONEncoder{}, nil
+	case ContentTypeProtobuf:
+		return ProtobufEncoder{}, nil
+	case ContentTypeAvro:
+		return AvroEncoder{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported content type %q", contentType)
+	}
+}
+
+// JSONEncoder encodes events as JSON
+type JSONEncoder struct{}
+
+// ContentType returns the JSON content type
+func (JSONEncoder) ContentType() string {
+	return ContentTypeJSON
+}
+
+// Encode encodes an event as JSON
+func (JSONEncoder) Encode(event APILogEvent) ([]byte, error) {
+	return json.Marshal(event)
+}
+
+// Decode decodes a JSON event
+func (JSONEncoder) Decode(data []byte) (APILogEvent, error) {
+	var event APILogEvent
+	err := json.Unmarshal(data, &event)
+	return event, err
+}
+
+// DecodeMessage decodes the data and attributes of a published message into an API log event
+// It is meant for consumers and handles the compression and encoding signaled by
+// ContentEncodingAttribute and ContentTypeAttribute, for raw events as well as CloudEvents
+// (binary mode events are raw events with extra attributes)
+func DecodeMessage(data []byte, attributes map[string]string) (APILogEvent, error) {
+	contentType := attributes[ContentTypeAttribute]
+
+	data, err := Decompress(data, attributes[ContentEncodingAttribute])
+	if err != nil {
+		return APILogEvent{}, fmt.Errorf("decompress message: %w", err)
+	}
+
+	var event APILogEvent
+	if contentType == ContentTypeCloudEvents {
+		event, err = decodeCloudEvent(data)
+	} else {
+		var encoder Encoder
+		encoder, err = encoderForContentType(contentType)
+		if err != nil {
+			return APILogEvent{}, err
+		}
+		event, err = encoder.Decode(data)
+	}
+	if err != nil {
+		return APILogEvent{}, fmt.Errorf("decode message: %w", err)
+	}
+	return event, nil
+}
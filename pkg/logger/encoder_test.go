@@ -0,0 +1,423 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/linkedin/goavro/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"gopkg.in/guregu/null.v3"
+)
+
+func newTestEvent() APILogEvent {
+	return APILogEvent{
+		RequestID:                null.StringFrom("req-1"),
+		Service:                  "test-service",
+		URL:                      "/v1/items?limit=10",
+		Method:                   "GET",
+		ResponseCode:             200,
+		ResponseBody:             null.StringFrom(`[{"id":"1"}]`),
+		RequestBody:              null.StringFrom(""),
+		Duration:                 0.0125,
+		Version:                  "v1",
+		Name:                     "GetItems",
+		CreatedAt:                time.Date(2024, 5, 1, 12, 30, 0, 123456000, time.UTC),
+		Truncated:                true,
+		RequestBodySize:          0,
+		ResponseBodySize:         2048,
+		EncryptionKeyID:          "dk-1",
+		SampleRate:               0.25,
+		RequestHeaders:           map[string]string{"Accept": "application/json", "Authorization": "***REDACTED***"},
+		ResponseHeaders:          map[string]string{"Content-Type": "application/json", "Cache-Control": ""},
+		ResponseMode:             ResponseModeStreamed,
+		ResponseBodyCapturedSize: 1024,
+		Panic:                    "boom",
+		PanicStack:               "main.handler()\n\t/app/main.go:42",
+		ClientIP:                 "203.0.113.7",
+		UserAgent:                "curl/8.5.0",
+		Referer:                  "https://example.com/items",
+		Protocol:                 "HTTP/2.0",
+		Host:                     "api.example.com",
+		TLSVersion:               "TLS 1.3",
+		RequestBytes:             0,
+		ResponseBytes:            5 << 30,
+		EventID:                  "2f1c6a52-7c1e-4a53-9b1e-3c5d8f0a1b2c",
+	}
+}
+
+func TestEncoders_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		event APILogEvent
+	}{
+		{name: "full event", event: newTestEvent()},
+		{name: "null fields", event: APILogEvent{Service: "test-service", Method: "POST", ResponseCode: 201, CreatedAt: time.Unix(0, 0).UTC()}},
+	}
+
+	for _, encoding := range []string{"json", "protobuf", "avro"} {
+		encoder, err := NewEncoder(encoding)
+		if err != nil {
+			t.Fatalf("NewEncoder(%q) error = %v", encoding, err)
+		}
+
+		for _, tt := range tests {
+			t.Run(encoding+"/"+tt.name, func(t *testing.T) {
+				data, err := encoder.Encode(tt.event)
+				if err != nil {
+					t.Fatalf("Encode() error = %v", err)
+				}
+
+				got, err := encoder.Decode(data)
+				if err != nil {
+					t.Fatalf("Decode() error = %v", err)
+				}
+				if !got.CreatedAt.Equal(tt.event.CreatedAt) {
+					t.Errorf("Expected created_at = %v, got %v", tt.event.CreatedAt, got.CreatedAt)
+				}
+				got.CreatedAt = tt.event.CreatedAt
+				if !reflect.DeepEqual(got, tt.event) {
+					t.Errorf("Expected %+v, got %+v", tt.event, got)
+				}
+			})
+		}
+	}
+}
+
+func TestProtobufEncoder_WireFormat(t *testing.T) {
+	event := APILogEvent{
+		RequestID:    null.StringFrom(""),
+		Service:      "svc",
+		ResponseCode: 200,
+		CreatedAt:    time.UnixMicro(0),
+	}
+
+	data, err := ProtobufEncoder{}.Encode(event)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	// request_id (1) is set even though empty, service (2) and response_code (5) as varint 200
+	want := []byte{0x0a, 0x00, 0x12, 0x03, 's', 'v', 'c', 0x28, 0xc8, 0x01}
+	if !bytes.Equal(data, want) {
+		t.Errorf("Expected %x, got %x", want, data)
+	}
+}
+
+func TestProtobufEncoder_MapWireFormat(t *testing.T) {
+	data, err := ProtobufEncoder{}.Encode(APILogEvent{
+		RequestHeaders: map[string]string{"B": "2", "A": "1"},
+		CreatedAt:      time.UnixMicro(0),
+	})
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	// request_headers (18) as one key (1) and value (2) entry per header, in key order
+	want := []byte{
+		0x92, 0x01, 0x06, 0x0a, 0x01, 'A', 0x12, 0x01, '1',
+		0x92, 0x01, 0x06, 0x0a, 0x01, 'B', 0x12, 0x01, '2',
+	}
+	if !bytes.Equal(data, want) {
+		t.Errorf("Expected %x, got %x", want, data)
+	}
+}
+
+func TestProtobufEncoder_SkipsUnknownFields(t *testing.T) {
+	// Field 100 (string "x") followed by service (2)
+	data := []byte{0xa2, 0x06, 0x01, 'x', 0x12, 0x03, 's', 'v', 'c'}
+
+	event, err := ProtobufEncoder{}.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if event.Service != "svc" {
+		t.Errorf("Expected service = svc, got %q", event.Service)
+	}
+}
+
+func TestAvroEncoder_WireFormat(t *testing.T) {
+	data, err := AvroEncoder{}.Encode(APILogEvent{Service: "svc", ResponseCode: -1, CreatedAt: time.UnixMicro(1)})
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	want := []byte{
+		0x00,                // request_id: null
+		0x06, 's', 'v', 'c', // service
+		0x00, 0x00, // url, method: ""
+		0x01,             // response_code: -1 zigzag encoded
+		0x00, 0x00, 0x00, // response_body, request_body, user_id: null
+		0, 0, 0, 0, 0, 0, 0, 0, // duration
+		0x00, 0x00, // version, name: ""
+		0x02,       // created_at: 1
+		0x00,       // truncated
+		0x00, 0x00, // body sizes
+		0x00,                   // encryption_key_id: ""
+		0, 0, 0, 0, 0, 0, 0, 0, // sample_rate
+		0x00, 0x00, // request_headers, response_headers: empty maps
+		0x00,       // response_mode: ""
+		0x00, 0x00, // captured body sizes
+		0x00, 0x00, // panic, panic_stack: ""
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // client_ip, user_agent, referer, protocol, host, tls_version: ""
+		0x00, 0x00, // request_bytes, response_bytes
+		0x00, // event_id: ""
+	}
+	if !bytes.Equal(data, want) {
+		t.Errorf("Expected %x, got %x", want, data)
+	}
+}
+
+// newSchemaTestEvent returns an event with every field set, and sizes that do not fit in 32 bits
+func newSchemaTestEvent() APILogEvent {
+	event := newTestEvent()
+	event.UserID = null.StringFrom("user-1")
+	event.RequestBodySize = 3 << 30
+	event.RequestBodyCapturedSize = 3 << 30
+	event.RequestBytes = 3 << 30
+	return event
+}
+
+// schemaTestValues returns the fields of newSchemaTestEvent by schema field name
+// String unions are nil when null, and created_at is in microseconds
+func schemaTestValues(event APILogEvent) map[string]any {
+	return map[string]any{
+		"request_id":                  event.RequestID.String,
+		"service":                     event.Service,
+		"url":                         event.URL,
+		"method":                      event.Method,
+		"response_code":               int32(event.ResponseCode),
+		"response_body":               event.ResponseBody.String,
+		"request_body":                event.RequestBody.String,
+		"user_id":                     event.UserID.String,
+		"duration":                    event.Duration,
+		"version":                     event.Version,
+		"name":                        event.Name,
+		"created_at":                  event.CreatedAt.UnixMicro(),
+		"truncated":                   event.Truncated,
+		"request_body_size":           int64(event.RequestBodySize),
+		"response_body_size":          int64(event.ResponseBodySize),
+		"encryption_key_id":           event.EncryptionKeyID,
+		"sample_rate":                 event.SampleRate,
+		"request_headers":             event.RequestHeaders,
+		"response_headers":            event.ResponseHeaders,
+		"response_mode":               event.ResponseMode,
+		"request_body_captured_size":  int64(event.RequestBodyCapturedSize),
+		"response_body_captured_size": int64(event.ResponseBodyCapturedSize),
+		"panic":                       event.Panic,
+		"panic_stack":                 event.PanicStack,
+		"client_ip":                   event.ClientIP,
+		"user_agent":                  event.UserAgent,
+		"referer":                     event.Referer,
+		"protocol":                    event.Protocol,
+		"host":                        event.Host,
+		"tls_version":                 event.TLSVersion,
+		"request_bytes":               event.RequestBytes,
+		"response_bytes":              event.ResponseBytes,
+		"event_id":                    event.EventID,
+	}
+}
+
+func TestProtobufEncoder_MatchesSchema(t *testing.T) {
+	compiler := protocompile.Compiler{
+		Resolver: &protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(map[string]string{"api_log.proto": ProtobufSchema}),
+		},
+	}
+	files, err := compiler.Compile(context.Background(), "api_log.proto")
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	desc := files[0].Messages().ByName("APILogEvent")
+
+	event := newSchemaTestEvent()
+	data, err := ProtobufEncoder{}.Encode(event)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	// Fields with a number or wire type that does not match the schema end up as unknown fields
+	msg := dynamicpb.NewMessage(desc)
+	if err := proto.Unmarshal(data, msg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if unknown := msg.GetUnknown(); len(unknown) > 0 {
+		t.Errorf("Expected every field to match the schema, got %d bytes of unknown fields", len(unknown))
+	}
+
+	expected := schemaTestValues(event)
+	if desc.Fields().Len() != len(expected) {
+		t.Errorf("Expected %d fields in the schema, got %d", len(expected), desc.Fields().Len())
+	}
+	for name, want := range expected {
+		field := desc.Fields().ByName(protoreflect.Name(name))
+		if field == nil {
+			t.Errorf("Expected field %s in the schema", name)
+			continue
+		}
+
+		var got any = msg.Get(field).Interface()
+		if field.IsMap() {
+			headers := map[string]string{}
+			msg.Get(field).Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+				headers[k.String()] = v.String()
+				return true
+			})
+			got = headers
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %s = %v (%T), got %v (%T)", name, want, want, got, got)
+		}
+	}
+
+	// Messages encoded from the schema decode to the same event
+	data, err = proto.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	decoded, err := ProtobufEncoder{}.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(decoded, event) {
+		t.Errorf("Expected decoded event = %+v, got %+v", event, decoded)
+	}
+}
+
+func TestAvroEncoder_MatchesSchema(t *testing.T) {
+	codec, err := goavro.NewCodec(AvroSchema)
+	if err != nil {
+		t.Fatalf("NewCodec() error = %v", err)
+	}
+
+	event := newSchemaTestEvent()
+	data, err := AvroEncoder{}.Encode(event)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	native, rest, err := codec.NativeFromBinary(data)
+	if err != nil {
+		t.Fatalf("NativeFromBinary() error = %v", err)
+	}
+	if len(rest) > 0 {
+		t.Errorf("Expected every field to match the schema, got %d trailing bytes", len(rest))
+	}
+	record := native.(map[string]any)
+
+	expected := schemaTestValues(event)
+	if len(record) != len(expected) {
+		t.Errorf("Expected %d fields in the schema, got %d", len(expected), len(record))
+	}
+	for name, want := range expected {
+		got := record[name]
+		switch v := got.(type) {
+		case map[string]any:
+			if union, ok := v["string"]; ok && len(v) == 1 {
+				got = union
+				break
+			}
+			headers := map[string]string{}
+			for key, value := range v {
+				headers[key] = value.(string)
+			}
+			got = headers
+		case time.Time:
+			got = v.UnixMicro()
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %s = %v (%T), got %v (%T)", name, want, want, got, got)
+		}
+	}
+
+	// Records encoded from the schema decode to the same event
+	data, err = codec.BinaryFromNative(nil, record)
+	if err != nil {
+		t.Fatalf("BinaryFromNative() error = %v", err)
+	}
+	decoded, err := AvroEncoder{}.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(decoded, event) {
+		t.Errorf("Expected decoded event = %+v, got %+v", event, decoded)
+	}
+}
+
+func TestDecoders_RejectTruncatedData(t *testing.T) {
+	for _, encoder := range []Encoder{ProtobufEncoder{}, AvroEncoder{}} {
+		data, err := encoder.Encode(newTestEvent())
+		if err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+		if _, err := encoder.Decode(data[:len(data)/2]); err == nil {
+			t.Errorf("Expected %s decoder to reject truncated data", encoder.ContentType())
+		}
+	}
+}
+
+func TestNewEncoder_RejectsUnknownEncoding(t *testing.T) {
+	if _, err := NewEncoder("xml"); err == nil {
+		t.Error("Expected error for unknown encoding")
+	}
+}
+
+func TestDecodeMessage(t *testing.T) {
+	event := newTestEvent()
+	jsonData, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("Failed to marshal event: %v", err)
+	}
+	compressed, err := Compress(jsonData, EncodingZstd)
+	if err != nil {
+		t.Fatalf("Compress() error = %v", err)
+	}
+	avroData, err := AvroEncoder{}.Encode(event)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	compressedProto, err := ProtobufEncoder{}.Encode(event)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	compressedProto, err = Compress(compressedProto, EncodingGzip)
+	if err != nil {
+		t.Fatalf("Compress() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		attributes map[string]string
+		wantErr    bool
+	}{
+		{name: "plain json", data: jsonData},
+		{name: "compressed json", data: compressed, attributes: map[string]string{ContentEncodingAttribute: EncodingZstd}},
+		{name: "avro", data: avroData, attributes: map[string]string{ContentTypeAttribute: ContentTypeAvro}},
+		{name: "compressed protobuf", data: compressedProto, attributes: map[string]string{
+			ContentTypeAttribute:     ContentTypeProtobuf,
+			ContentEncodingAttribute: EncodingGzip,
+		}},
+		{name: "missing encoding attribute", data: compressed, wantErr: true},
+		{name: "wrong encoding attribute", data: jsonData, attributes: map[string]string{ContentEncodingAttribute: EncodingGzip}, wantErr: true},
+		{name: "unknown content type", data: jsonData, attributes: map[string]string{ContentTypeAttribute: "text/xml"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeMessage(tt.data, tt.attributes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.RequestID.String != "req-1" {
+				t.Errorf("Expected request ID = req-1, got %v", got.RequestID)
+			}
+		})
+	}
+}
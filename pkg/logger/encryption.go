@@ -0,0 +1,127 @@
+package logger
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+// ErrUnknownEncryptionKey is returned when decrypting an event encrypted with a key missing from the keyring
+var ErrUnknownEncryptionKey = errors.New("event is encrypted with an unknown key")
+
+// Additional data binding each ciphertext to its field, so bodies cannot be swapped
+var (
+	requestBodyAAD  = []byte("request_body")
+	responseBodyAAD = []byte("response_body")
+)
+
+// BodyEncrypter encrypts the request and response bodies of events with AES-GCM
+// Metadata fields are left in the clear so events can still be filtered
+type BodyEncrypter struct {
+	keyID string
+	aead  cipher.AEAD
+}
+
+// NewBodyEncrypter returns an encrypter using the data key keyID of keys (16, 24 or 32 bytes)
+func NewBodyEncrypter(keys Keyring, keyID string) (*BodyEncrypter, error) {
+	key, ok := keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("encryption key %q is not in the keyring", keyID)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key %q: %w", keyID, err)
+	}
+	return &BodyEncrypter{keyID: keyID, aead: aead}, nil
+}
+
+// EncryptBodies returns a copy of the event with its bodies encrypted and EncryptionKeyID set
+// Each body is replaced by the base64 encoded nonce and ciphertext
+func (e *BodyEncrypter) EncryptBodies(event APILogEvent) (APILogEvent, error) {
+	if event.EncryptionKeyID != "" {
+		return event, fmt.Errorf("event is already encrypted with key %q", event.EncryptionKeyID)
+	}
+
+	var err error
+	if event.RequestBody, err = e.encrypt(event.RequestBody, requestBodyAAD); err != nil {
+		return event, err
+	}
+	if event.ResponseBody, err = e.encrypt(event.ResponseBody, responseBodyAAD); err != nil {
+		return event, err
+	}
+	event.EncryptionKeyID = e.keyID
+	return event, nil
+}
+
+func (e *BodyEncrypter) encrypt(body null.String, aad []byte) (null.String, error) {
+	if !body.Valid {
+		return body, nil
+	}
+
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return body, err
+	}
+	sealed := e.aead.Seal(nonce, nonce, []byte(body.String), aad)
+	return null.StringFrom(base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// DecryptBodies returns a copy of the event with its bodies decrypted using the key named by EncryptionKeyID
+// Events in the clear are returned unchanged
+func DecryptBodies(event APILogEvent, keys Keyring) (APILogEvent, error) {
+	if event.EncryptionKeyID == "" {
+		return event, nil
+	}
+
+	key, ok := keys[event.EncryptionKeyID]
+	if !ok {
+		return event, fmt.Errorf("%w: %q", ErrUnknownEncryptionKey, event.EncryptionKeyID)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return event, fmt.Errorf("encryption key %q: %w", event.EncryptionKeyID, err)
+	}
+
+	if event.RequestBody, err = decryptBody(aead, event.RequestBody, requestBodyAAD); err != nil {
+		return event, fmt.Errorf("decrypt request body: %w", err)
+	}
+	if event.ResponseBody, err = decryptBody(aead, event.ResponseBody, responseBodyAAD); err != nil {
+		return event, fmt.Errorf("decrypt response body: %w", err)
+	}
+	event.EncryptionKeyID = ""
+	return event, nil
+}
+
+func decryptBody(aead cipher.AEAD, body null.String, aad []byte) (null.String, error) {
+	if !body.Valid {
+		return body, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(body.String)
+	if err != nil {
+		return body, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return body, errors.New("ciphertext is too short")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return body, err
+	}
+	return null.StringFrom(string(plaintext)), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
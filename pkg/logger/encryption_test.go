@@ -0,0 +1,130 @@
+package logger
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+func newTestDataKeys() Keyring {
+	return Keyring{
+		"dk-1": []byte("0123456789abcdef0123456789abcdef"),
+		"dk-2": []byte("fedcba9876543210"),
+	}
+}
+
+func TestBodyEncrypter_RoundTrip(t *testing.T) {
+	keys := newTestDataKeys()
+	encrypter, err := NewBodyEncrypter(keys, "dk-1")
+	if err != nil {
+		t.Fatalf("NewBodyEncrypter() error = %v", err)
+	}
+
+	event := newTestEventInClear()
+	event.RequestBody = null.StringFrom(`{"email":"jane@example.com"}`)
+
+	encrypted, err := encrypter.EncryptBodies(event)
+	if err != nil {
+		t.Fatalf("EncryptBodies() error = %v", err)
+	}
+
+	if encrypted.EncryptionKeyID != "dk-1" {
+		t.Errorf("Expected encryption key ID = dk-1, got %q", encrypted.EncryptionKeyID)
+	}
+	if strings.Contains(encrypted.RequestBody.String, "jane") || encrypted.ResponseBody.String == event.ResponseBody.String {
+		t.Error("Expected bodies to be encrypted")
+	}
+	if encrypted.Service != event.Service || encrypted.ResponseCode != event.ResponseCode {
+		t.Error("Expected metadata fields to stay in the clear")
+	}
+
+	decrypted, err := DecryptBodies(encrypted, keys)
+	if err != nil {
+		t.Fatalf("DecryptBodies() error = %v", err)
+	}
+	if !reflect.DeepEqual(decrypted, event) {
+		t.Errorf("Expected %+v, got %+v", event, decrypted)
+	}
+}
+
+func TestBodyEncrypter_KeepsNullBodies(t *testing.T) {
+	encrypter, err := NewBodyEncrypter(newTestDataKeys(), "dk-2")
+	if err != nil {
+		t.Fatalf("NewBodyEncrypter() error = %v", err)
+	}
+
+	encrypted, err := encrypter.EncryptBodies(APILogEvent{Service: "test-service"})
+	if err != nil {
+		t.Fatalf("EncryptBodies() error = %v", err)
+	}
+	if encrypted.RequestBody.Valid || encrypted.ResponseBody.Valid {
+		t.Errorf("Expected null bodies to stay null, got %+v", encrypted)
+	}
+
+	if _, err := encrypter.EncryptBodies(encrypted); err == nil {
+		t.Error("Expected error when encrypting an encrypted event")
+	}
+}
+
+func TestDecryptBodies_Errors(t *testing.T) {
+	keys := newTestDataKeys()
+	encrypter, err := NewBodyEncrypter(keys, "dk-1")
+	if err != nil {
+		t.Fatalf("NewBodyEncrypter() error = %v", err)
+	}
+	encrypted, err := encrypter.EncryptBodies(newTestEventInClear())
+	if err != nil {
+		t.Fatalf("EncryptBodies() error = %v", err)
+	}
+
+	swapped := encrypted
+	swapped.RequestBody, swapped.ResponseBody = encrypted.ResponseBody, encrypted.RequestBody
+
+	tests := []struct {
+		name    string
+		event   APILogEvent
+		keys    Keyring
+		wantErr error
+	}{
+		{name: "unknown key", event: encrypted, keys: Keyring{"dk-2": keys["dk-2"]}, wantErr: ErrUnknownEncryptionKey},
+		{name: "wrong key", event: encrypted, keys: Keyring{"dk-1": []byte("another-key-0123another-key-0123")}},
+		{name: "swapped bodies", event: swapped, keys: keys},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecryptBodies(tt.event, tt.keys)
+			if err == nil {
+				t.Fatal("Expected decryption error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected error = %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestNewBodyEncrypter_RejectsInvalidKeys(t *testing.T) {
+	if _, err := NewBodyEncrypter(newTestDataKeys(), "dk-3"); err == nil {
+		t.Error("Expected error for key ID missing from the keyring")
+	}
+	if _, err := NewBodyEncrypter(Keyring{"short": []byte("too-short")}, "short"); err == nil {
+		t.Error("Expected error for invalid AES key size")
+	}
+}
+
+func TestTruncateBodies_SkipsEncryptedBodies(t *testing.T) {
+	event := APILogEvent{ResponseBody: null.StringFrom("ciphertext"), EncryptionKeyID: "dk-1"}
+	if got := event.TruncateBodies(4); !reflect.DeepEqual(got, event) {
+		t.Errorf("Expected encrypted event to be unchanged, got %+v", got)
+	}
+}
+
+func newTestEventInClear() APILogEvent {
+	event := newTestEvent()
+	event.EncryptionKeyID = ""
+	return event
+}
@@ -0,0 +1,357 @@
+package logger
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"gopkg.in/guregu/null.v3"
+)
+
+// ProtobufSchema is the protobuf definition of APILogEvent (see api_log.proto)
+//
+//go:embed api_log.proto
+var ProtobufSchema string
+
+// Field numbers of api_log.proto
+const (
+	protoRequestID        protowire.Number = 1
+	protoService          protowire.Number = 2
+	protoURL              protowire.Number = 3
+	protoMethod           protowire.Number = 4
+	protoResponseCode     protowire.Number = 5
+	protoResponseBody     protowire.Number = 6
+	protoRequestBody      protowire.Number = 7
+	protoUserID           protowire.Number = 8
+	protoDuration         protowire.Number = 9
+	protoVersion          protowire.Number = 10
+	protoName             protowire.Number = 11
+	protoCreatedAt        protowire.Number = 12
+	protoTruncated        protowire.Number = 13
+	protoRequestBodySize  protowire.Number = 14
+	protoResponseBodySize protowire.Number = 15
+	protoEncryptionKeyID  protowire.Number = 16
+	protoSampleRate       protowire.Number = 17
+	protoRequestHeaders   protowire.Number = 18
+	protoResponseHeaders  protowire.Number = 19
+	protoResponseMode     protowire.Number = 20
+	protoRequestCaptured  protowire.Number = 21
+	protoResponseCaptured protowire.Number = 22
+	protoPanic            protowire.Number = 23
+	protoPanicStack       protowire.Number = 24
+	protoClientIP         protowire.Number = 25
+	protoUserAgent        protowire.Number = 26
+	protoReferer          protowire.Number = 27
+	protoProtocol         protowire.Number = 28
+	protoHost             protowire.Number = 29
+	protoTLSVersion       protowire.Number = 30
+	protoRequestBytes     protowire.Number = 31
+	protoResponseBytes    protowire.Number = 32
+	protoEventID          protowire.Number = 33
+)
+
+// Field numbers of the map entries of api_log.proto
+const (
+	protoMapKey   protowire.Number = 1
+	protoMapValue protowire.Number = 2
+)
+
+// ProtobufEncoder encodes events in the protobuf wire format of api_log.proto
+type ProtobufEncoder struct{}
+
+// ContentType returns the protobuf content type
+func (ProtobufEncoder) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+// Encode encodes an event as protobuf, fields with their zero value are omitted as in proto3
+func (ProtobufEncoder) Encode(event APILogEvent) ([]byte, error) {
+	var b []byte
+
+	b = appendProtoNullString(b, protoRequestID, event.RequestID)
+	b = appendProtoString(b, protoService, event.Service)
+	b = appendProtoString(b, protoURL, event.URL)
+	b = appendProtoString(b, protoMethod, event.Method)
+	b = appendProtoVarint(b, protoResponseCode, uint64(int32(event.ResponseCode)))
+	b = appendProtoNullString(b, protoResponseBody, event.ResponseBody)
+	b = appendProtoNullString(b, protoRequestBody, event.RequestBody)
+	b = appendProtoNullString(b, protoUserID, event.UserID)
+	b = appendProtoDouble(b, protoDuration, event.Duration)
+	b = appendProtoString(b, protoVersion, event.Version)
+	b = appendProtoString(b, protoName, event.Name)
+	b = appendProtoVarint(b, protoCreatedAt, uint64(event.CreatedAt.UnixMicro()))
+	if event.Truncated {
+		b = appendProtoVarint(b, protoTruncated, 1)
+	}
+	b = appendProtoVarint(b, protoRequestBodySize, uint64(int64(event.RequestBodySize)))
+	b = appendProtoVarint(b, protoResponseBodySize, uint64(int64(event.ResponseBodySize)))
+	b = appendProtoString(b, protoEncryptionKeyID, event.EncryptionKeyID)
+	b = appendProtoDouble(b, protoSampleRate, event.SampleRate)
+	b = appendProtoMap(b, protoRequestHeaders, event.RequestHeaders)
+	b = appendProtoMap(b, protoResponseHeaders, event.ResponseHeaders)
+	b = appendProtoString(b, protoResponseMode, event.ResponseMode)
+	b = appendProtoVarint(b, protoRequestCaptured, uint64(int64(event.RequestBodyCapturedSize)))
+	b = appendProtoVarint(b, protoResponseCaptured, uint64(int64(event.ResponseBodyCapturedSize)))
+	b = appendProtoString(b, protoPanic, event.Panic)
+	b = appendProtoString(b, protoPanicStack, event.PanicStack)
+	b = appendProtoString(b, protoClientIP, event.ClientIP)
+	b = appendProtoString(b, protoUserAgent, event.UserAgent)
+	b = appendProtoString(b, protoReferer, event.Referer)
+	b = appendProtoString(b, protoProtocol, event.Protocol)
+	b = appendProtoString(b, protoHost, event.Host)
+	b = appendProtoString(b, protoTLSVersion, event.TLSVersion)
+	b = appendProtoVarint(b, protoRequestBytes, uint64(event.RequestBytes))
+	b = appendProtoVarint(b, protoResponseBytes, uint64(event.ResponseBytes))
+	b = appendProtoString(b, protoEventID, event.EventID)
+
+	return b, nil
+}
+
+// Decode decodes a protobuf event, unknown fields are skipped
+func (ProtobufEncoder) Decode(data []byte) (APILogEvent, error) {
+	// A missing created_at is the epoch, as for any proto3 scalar
+	event := APILogEvent{CreatedAt: time.UnixMicro(0).UTC()}
+
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return APILogEvent{}, protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		switch {
+		case typ == protowire.BytesType && isProtoStringField(num):
+			v, n := protowire.ConsumeString(data)
+			if n < 0 {
+				return APILogEvent{}, protowire.ParseError(n)
+			}
+			data = data[n:]
+			setProtoString(&event, num, v)
+		case typ == protowire.VarintType && isProtoVarintField(num):
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return APILogEvent{}, protowire.ParseError(n)
+			}
+			data = data[n:]
+			setProtoVarint(&event, num, v)
+		case typ == protowire.Fixed64Type && (num == protoDuration || num == protoSampleRate):
+			v, n := protowire.ConsumeFixed64(data)
+			if n < 0 {
+				return APILogEvent{}, protowire.ParseError(n)
+			}
+			data = data[n:]
+			if num == protoDuration {
+				event.Duration = math.Float64frombits(v)
+			} else {
+				event.SampleRate = math.Float64frombits(v)
+			}
+		case typ == protowire.BytesType && (num == protoRequestHeaders || num == protoResponseHeaders):
+			v, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return APILogEvent{}, protowire.ParseError(n)
+			}
+			data = data[n:]
+			headers := &event.RequestHeaders
+			if num == protoResponseHeaders {
+				headers = &event.ResponseHeaders
+			}
+			if err := consumeProtoMapEntry(headers, v); err != nil {
+				return APILogEvent{}, err
+			}
+		default:
+			if num >= protoRequestID && num <= protoEventID {
+				return APILogEvent{}, fmt.Errorf("unexpected wire type %d for field %d", typ, num)
+			}
+			n := protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return APILogEvent{}, protowire.ParseError(n)
+			}
+			data = data[n:]
+		}
+	}
+
+	return event, nil
+}
+
+func isProtoStringField(num protowire.Number) bool {
+	switch num {
+	case protoRequestID, protoService, protoURL, protoMethod, protoResponseBody,
+		protoRequestBody, protoUserID, protoVersion, protoName, protoEncryptionKeyID, protoResponseMode,
+		protoPanic, protoPanicStack, protoClientIP, protoUserAgent, protoReferer, protoProtocol, protoHost,
+		protoTLSVersion, protoEventID:
+		return true
+	}
+	return false
+}
+
+func isProtoVarintField(num protowire.Number) bool {
+	switch num {
+	case protoResponseCode, protoCreatedAt, protoTruncated, protoRequestBodySize, protoResponseBodySize,
+		protoRequestCaptured, protoResponseCaptured, protoRequestBytes, protoResponseBytes:
+		return true
+	}
+	return false
+}
+
+func setProtoString(event *APILogEvent, num protowire.Number, v string) {
+	switch num {
+	case protoRequestID:
+		event.RequestID = null.StringFrom(v)
+	case protoService:
+		event.Service = v
+	case protoURL:
+		event.URL = v
+	case protoMethod:
+		event.Method = v
+	case protoResponseBody:
+		event.ResponseBody = null.StringFrom(v)
+	case protoRequestBody:
+		event.RequestBody = null.StringFrom(v)
+	case protoUserID:
+		event.UserID = null.StringFrom(v)
+	case protoVersion:
+		event.Version = v
+	case protoName:
+		event.Name = v
+	case protoEncryptionKeyID:
+		event.EncryptionKeyID = v
+	case protoResponseMode:
+		event.ResponseMode = v
+	case protoPanic:
+		event.Panic = v
+	case protoPanicStack:
+		event.PanicStack = v
+	case protoClientIP:
+		event.ClientIP = v
+	case protoUserAgent:
+		event.UserAgent = v
+	case protoReferer:
+		event.Referer = v
+	case protoProtocol:
+		event.Protocol = v
+	case protoHost:
+		event.Host = v
+	case protoTLSVersion:
+		event.TLSVersion = v
+	case protoEventID:
+		event.EventID = v
+	}
+}
+
+func setProtoVarint(event *APILogEvent, num protowire.Number, v uint64) {
+	switch num {
+	case protoResponseCode:
+		event.ResponseCode = int(int32(v))
+	case protoCreatedAt:
+		event.CreatedAt = time.UnixMicro(int64(v)).UTC()
+	case protoTruncated:
+		event.Truncated = v != 0
+	case protoRequestBodySize:
+		event.RequestBodySize = int(int64(v))
+	case protoResponseBodySize:
+		event.ResponseBodySize = int(int64(v))
+	case protoRequestCaptured:
+		event.RequestBodyCapturedSize = int(int64(v))
+	case protoResponseCaptured:
+		event.ResponseBodyCapturedSize = int(int64(v))
+	case protoRequestBytes:
+		event.RequestBytes = int64(v)
+	case protoResponseBytes:
+		event.ResponseBytes = int64(v)
+	}
+}
+
+// appendProtoString appends a string field unless it is empty
+func appendProtoString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+// appendProtoNullString appends an optional string field when it is set, even if empty
+func appendProtoNullString(b []byte, num protowire.Number, v null.String) []byte {
+	if !v.Valid {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v.String)
+}
+
+// appendProtoDouble appends a double field unless it is zero
+func appendProtoDouble(b []byte, num protowire.Number, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
+
+// appendProtoMap appends a map<string, string> field as one entry message per key, in key order
+func appendProtoMap(b []byte, num protowire.Number, m map[string]string) []byte {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		var entry []byte
+		entry = appendProtoString(entry, protoMapKey, k)
+		entry = appendProtoString(entry, protoMapValue, m[k])
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	return b
+}
+
+// consumeProtoMapEntry decodes a map<string, string> entry message into m, allocating it if needed
+func consumeProtoMapEntry(m *map[string]string, data []byte) error {
+	var key, value string
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		if typ == protowire.BytesType && (num == protoMapKey || num == protoMapValue) {
+			v, n := protowire.ConsumeString(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+			if num == protoMapKey {
+				key = v
+			} else {
+				value = v
+			}
+			continue
+		}
+
+		n = protowire.ConsumeFieldValue(num, typ, data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+
+	if *m == nil {
+		*m = make(map[string]string)
+	}
+	(*m)[key] = value
+	return nil
+}
+
+// appendProtoVarint appends a varint field unless it is zero
+func appendProtoVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
@@ -0,0 +1,135 @@
+package logger
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Message attributes carrying the HMAC-SHA256 signature of the message data and attributes,
+// and the ID of the signing key
+const (
+	SignatureAttribute      = "signature"
+	SignatureKeyIDAttribute = "signature-key-id"
+)
+
+// reservedAttributePrefix is the prefix of the attributes Pub/Sub adds itself (e.g. for bound schemas),
+// they are not signed
+const reservedAttributePrefix = "googclient_"
+
+var (
+	// ErrMissingSignature is returned when verifying a message without signature attributes
+	ErrMissingSignature = errors.New("message is not signed")
+	// ErrUnknownSigningKey is returned when a message is signed with a key missing from the keyring
+	ErrUnknownSigningKey = errors.New("message is signed with an unknown key")
+	// ErrInvalidSignature is returned when the signature does not match the message data or attributes
+	ErrInvalidSignature = errors.New("message signature is invalid")
+)
+
+// Keyring holds the active signing or encryption keys by key ID
+// During a rotation it holds both the old and the new key, so messages from either can be read
+type Keyring map[string][]byte
+
+// ParseKeyring decodes base64 encoded keys by key ID
+func ParseKeyring(encoded map[string]string) (Keyring, error) {
+	keys := make(Keyring, len(encoded))
+	for keyID, value := range encoded {
+		key, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", keyID, err)
+		}
+		if len(key) == 0 {
+			return nil, fmt.Errorf("key %q is empty", keyID)
+		}
+		keys[keyID] = key
+	}
+	return keys, nil
+}
+
+// Signer signs message data and attributes with a key of the keyring
+type Signer struct {
+	keyID string
+	key   []byte
+}
+
+// NewSigner returns a signer using the key keyID of keys
+func NewSigner(keys Keyring, keyID string) (*Signer, error) {
+	key, ok := keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q is not in the keyring", keyID)
+	}
+	return &Signer{keyID: keyID, key: key}, nil
+}
+
+// KeyID returns the ID of the signing key
+func (s *Signer) KeyID() string {
+	return s.keyID
+}
+
+// Sign returns the base64 encoded HMAC-SHA256 of data and attributes (see SignedContent)
+func (s *Signer) Sign(data []byte, attributes map[string]string) string {
+	return base64.StdEncoding.EncodeToString(computeHMAC(s.key, SignedContent(data, attributes)))
+}
+
+// SignatureAttributes returns the signature attributes of a message with data and attributes
+func (s *Signer) SignatureAttributes(data []byte, attributes map[string]string) map[string]string {
+	return map[string]string{
+		SignatureAttribute:      s.Sign(data, attributes),
+		SignatureKeyIDAttribute: s.keyID,
+	}
+}
+
+// SignedContent returns the bytes a message signature covers: the number of signed attributes,
+// each signed attribute in name order as its name and value, and then the data
+// Counts and lengths are 4-byte big-endian, the signature attributes and those added by Pub/Sub
+// (googclient_ prefix) are not signed
+func SignedContent(data []byte, attributes map[string]string) []byte {
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		if name == SignatureAttribute || name == SignatureKeyIDAttribute || strings.HasPrefix(name, reservedAttributePrefix) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b := binary.BigEndian.AppendUint32(nil, uint32(len(names)))
+	for _, name := range names {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(name)))
+		b = append(b, name...)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(attributes[name])))
+		b = append(b, attributes[name]...)
+	}
+	return append(b, data...)
+}
+
+// VerifyMessage checks the signature attributes of a published message against its data and attributes
+// It returns the ID of the key that signed the message
+func VerifyMessage(data []byte, attributes map[string]string, keys Keyring) (string, error) {
+	signature, keyID := attributes[SignatureAttribute], attributes[SignatureKeyIDAttribute]
+	if signature == "" || keyID == "" {
+		return "", ErrMissingSignature
+	}
+
+	key, ok := keys[keyID]
+	if !ok {
+		return keyID, fmt.Errorf("%w: %q", ErrUnknownSigningKey, keyID)
+	}
+
+	mac, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, computeHMAC(key, SignedContent(data, attributes))) {
+		return keyID, ErrInvalidSignature
+	}
+	return keyID, nil
+}
+
+func computeHMAC(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,169 @@
+package logger
+
+import (
+	"errors"
+	"testing"
+)
+
+func newTestKeyring(t *testing.T) Keyring {
+	t.Helper()
+
+	// "old-key-secret" and "new-key-secret"
+	keys, err := ParseKeyring(map[string]string{
+		"2024-01": "b2xkLWtleS1zZWNyZXQ=",
+		"2024-06": "bmV3LWtleS1zZWNyZXQ=",
+	})
+	if err != nil {
+		t.Fatalf("ParseKeyring() error = %v", err)
+	}
+	return keys
+}
+
+func TestVerifyMessage(t *testing.T) {
+	keys := newTestKeyring(t)
+	data := []byte(`{"request_id":"req-1"}`)
+
+	oldSigner, err := NewSigner(keys, "2024-01")
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	newSigner, err := NewSigner(keys, "2024-06")
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+
+	attributes := map[string]string{ContentTypeAttribute: ContentTypeJSON, ContentEncodingAttribute: EncodingGzip}
+
+	// signed returns attributes with changes applied (empty values are removed) and signer's signature
+	signed := func(signer *Signer, changes map[string]string) map[string]string {
+		signedAttributes := map[string]string{}
+		for name, value := range attributes {
+			signedAttributes[name] = value
+		}
+		for name, value := range signer.SignatureAttributes(data, attributes) {
+			signedAttributes[name] = value
+		}
+		for name, value := range changes {
+			if value == "" {
+				delete(signedAttributes, name)
+			} else {
+				signedAttributes[name] = value
+			}
+		}
+		return signedAttributes
+	}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		attributes map[string]string
+		keys       Keyring
+		wantKeyID  string
+		wantErr    error
+	}{
+		{name: "signed with new key", data: data, attributes: signed(newSigner, nil), keys: keys, wantKeyID: "2024-06"},
+		{name: "signed with old key during rotation", data: data, attributes: signed(oldSigner, nil), keys: keys, wantKeyID: "2024-01"},
+		{
+			name:       "old key retired",
+			data:       data,
+			attributes: signed(oldSigner, nil),
+			keys:       Keyring{"2024-06": keys["2024-06"]},
+			wantKeyID:  "2024-01",
+			wantErr:    ErrUnknownSigningKey,
+		},
+		{name: "tampered data", data: []byte(`{"request_id":"req-2"}`), attributes: signed(newSigner, nil), keys: keys, wantKeyID: "2024-06", wantErr: ErrInvalidSignature},
+		{
+			name:       "tampered attribute",
+			data:       data,
+			attributes: signed(newSigner, map[string]string{ContentTypeAttribute: ContentTypeProtobuf}),
+			keys:       keys,
+			wantKeyID:  "2024-06",
+			wantErr:    ErrInvalidSignature,
+		},
+		{
+			name:       "removed attribute",
+			data:       data,
+			attributes: signed(newSigner, map[string]string{ContentEncodingAttribute: ""}),
+			keys:       keys,
+			wantKeyID:  "2024-06",
+			wantErr:    ErrInvalidSignature,
+		},
+		{
+			name:       "added attribute",
+			data:       data,
+			attributes: signed(newSigner, map[string]string{"ce-type": "com.example.other"}),
+			keys:       keys,
+			wantKeyID:  "2024-06",
+			wantErr:    ErrInvalidSignature,
+		},
+		{
+			name:       "attribute added by Pub/Sub",
+			data:       data,
+			attributes: signed(newSigner, map[string]string{"googclient_schemaencoding": "BINARY"}),
+			keys:       keys,
+			wantKeyID:  "2024-06",
+		},
+		{
+			name:       "signature from another key",
+			data:       data,
+			attributes: map[string]string{SignatureAttribute: oldSigner.Sign(data, nil), SignatureKeyIDAttribute: "2024-06"},
+			keys:       keys,
+			wantKeyID:  "2024-06",
+			wantErr:    ErrInvalidSignature,
+		},
+		{
+			name:       "malformed signature",
+			data:       data,
+			attributes: map[string]string{SignatureAttribute: "not base64!", SignatureKeyIDAttribute: "2024-06"},
+			keys:       keys,
+			wantKeyID:  "2024-06",
+			wantErr:    ErrInvalidSignature,
+		},
+		{name: "missing signature", data: data, keys: keys, wantErr: ErrMissingSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyID, err := VerifyMessage(tt.data, tt.attributes, tt.keys)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected error = %v, got %v", tt.wantErr, err)
+			}
+			if keyID != tt.wantKeyID {
+				t.Errorf("Expected key ID = %q, got %q", tt.wantKeyID, keyID)
+			}
+		})
+	}
+}
+
+func TestSignedContent_IsUnambiguous(t *testing.T) {
+	// An attribute cannot be moved into the data, or the data into an attribute
+	a := SignedContent([]byte("data"), map[string]string{"a": "b"})
+	b := SignedContent(append(SignedContent(nil, map[string]string{"a": "b"})[4:], "data"...), nil)
+	if string(a) == string(b) {
+		t.Errorf("Expected different signed content, got %x for both", a)
+	}
+}
+
+func TestParseKeyring_RejectsInvalidKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded map[string]string
+	}{
+		{name: "not base64", encoded: map[string]string{"k1": "not base64!"}},
+		{name: "empty key", encoded: map[string]string{"k1": ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseKeyring(tt.encoded); err == nil {
+				t.Error("Expected error for invalid key")
+			}
+		})
+	}
+}
+
+func TestNewSigner_RejectsUnknownKeyID(t *testing.T) {
+	if _, err := NewSigner(newTestKeyring(t), "2023-01"); err == nil {
+		t.Error("Expected error for key ID missing from the keyring")
+	}
+}
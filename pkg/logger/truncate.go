@@ -0,0 +1,43 @@
+package logger
+
+import (
+	"unicode/utf8"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+// TruncateBodies returns a copy of the event with the request and response bodies cut to maxBytes
+// The original sizes are recorded and Truncated is set when either body was cut, sizes already
+// recorded for bodies cut while being captured are kept as they are larger, a non-positive
+// maxBytes leaves the event unchanged
+// Encrypted bodies are left unchanged as cutting them would make them unreadable, truncate before encrypting
+func (e APILogEvent) TruncateBodies(maxBytes int) APILogEvent {
+	if maxBytes <= 0 || e.EncryptionKeyID != "" {
+		return e
+	}
+
+	if body, ok := truncateBody(e.RequestBody, maxBytes); ok {
+		e.RequestBodySize = max(e.RequestBodySize, len(e.RequestBody.String))
+		e.RequestBody = body
+		e.Truncated = true
+	}
+	if body, ok := truncateBody(e.ResponseBody, maxBytes); ok {
+		e.ResponseBodySize = max(e.ResponseBodySize, len(e.ResponseBody.String))
+		e.ResponseBody = body
+		e.Truncated = true
+	}
+	return e
+}
+
+// truncateBody cuts body to at most maxBytes without splitting a UTF-8 sequence
+func truncateBody(body null.String, maxBytes int) (null.String, bool) {
+	if !body.Valid || len(body.String) <= maxBytes {
+		return body, false
+	}
+
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(body.String[cut]) {
+		cut--
+	}
+	return null.StringFrom(body.String[:cut]), true
+}
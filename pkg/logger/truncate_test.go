@@ -0,0 +1,100 @@
+package logger
+
+import (
+	"testing"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestAPILogEvent_TruncateBodies(t *testing.T) {
+	tests := []struct {
+		name             string
+		requestBody      null.String
+		responseBody     null.String
+		requestSize      int
+		maxBytes         int
+		wantRequest      string
+		wantResponse     string
+		wantTruncated    bool
+		wantRequestSize  int
+		wantResponseSize int
+	}{
+		{
+			name:         "disabled",
+			requestBody:  null.StringFrom("abcdef"),
+			responseBody: null.StringFrom("abcdef"),
+			maxBytes:     0,
+			wantRequest:  "abcdef",
+			wantResponse: "abcdef",
+		},
+		{
+			name:         "within limit",
+			requestBody:  null.StringFrom("abc"),
+			responseBody: null.StringFrom("abcdef"),
+			maxBytes:     6,
+			wantRequest:  "abc",
+			wantResponse: "abcdef",
+		},
+		{
+			name:             "truncates response only",
+			requestBody:      null.StringFrom("abc"),
+			responseBody:     null.StringFrom("abcdefgh"),
+			maxBytes:         4,
+			wantRequest:      "abc",
+			wantResponse:     "abcd",
+			wantTruncated:    true,
+			wantResponseSize: 8,
+		},
+		{
+			name:             "truncates both",
+			requestBody:      null.StringFrom("abcdef"),
+			responseBody:     null.StringFrom("abcdefgh"),
+			maxBytes:         2,
+			wantRequest:      "ab",
+			wantResponse:     "ab",
+			wantTruncated:    true,
+			wantRequestSize:  6,
+			wantResponseSize: 8,
+		},
+		{
+			name:            "does not split multi-byte characters",
+			requestBody:     null.StringFrom("aé€"),
+			maxBytes:        4,
+			wantRequest:     "aé",
+			wantTruncated:   true,
+			wantRequestSize: 6,
+		},
+		{
+			name:             "keeps the size of a body cut at capture",
+			requestBody:      null.StringFrom("abcdef"),
+			responseBody:     null.StringFrom("abcdefgh"),
+			requestSize:      5000000,
+			maxBytes:         2,
+			wantRequest:      "ab",
+			wantResponse:     "ab",
+			wantTruncated:    true,
+			wantRequestSize:  5000000,
+			wantResponseSize: 8,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := APILogEvent{RequestBody: tt.requestBody, ResponseBody: tt.responseBody, RequestBodySize: tt.requestSize}
+			got := event.TruncateBodies(tt.maxBytes)
+
+			if got.RequestBody.String != tt.wantRequest {
+				t.Errorf("Expected request body = %q, got %q", tt.wantRequest, got.RequestBody.String)
+			}
+			if got.ResponseBody.String != tt.wantResponse {
+				t.Errorf("Expected response body = %q, got %q", tt.wantResponse, got.ResponseBody.String)
+			}
+			if got.Truncated != tt.wantTruncated {
+				t.Errorf("Expected truncated = %v, got %v", tt.wantTruncated, got.Truncated)
+			}
+			if got.RequestBodySize != tt.wantRequestSize || got.ResponseBodySize != tt.wantResponseSize {
+				t.Errorf("Expected sizes = %d/%d, got %d/%d", tt.wantRequestSize, tt.wantResponseSize, got.RequestBodySize, got.ResponseBodySize)
+			}
+		})
+	}
+}